@@ -0,0 +1,37 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AccessGrantSpec struct {
+	Policy   string          `json:"policy"`
+	Subject  Subject         `json:"subject"`
+	Start    *metav1.Time    `json:"start,omitempty"`
+	Duration metav1.Duration `json:"duration"`
+}
+
+type AccessGrantStatus struct {
+	Conditions []metav1.Condition `json:"conditions"`
+	Phase      string             `json:"phase,omitempty"`
+	ExpiresAt  *metav1.Time       `json:"expiresAt,omitempty"`
+	Remaining  string             `json:"remaining,omitempty"`
+}
+
+type AccessGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessGrantSpec   `json:"spec,omitempty"`
+	Status AccessGrantStatus `json:"status,omitempty"`
+}
+
+type AccessGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessGrant{}, &AccessGrantList{})
+}
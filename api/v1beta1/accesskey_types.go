@@ -0,0 +1,38 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AccessKeySpec struct {
+	User           string           `json:"user"`
+	AccessKey      string           `json:"accessKey,omitempty"`
+	Policy         string           `json:"policy,omitempty"`
+	Expiration     *metav1.Time     `json:"expiration,omitempty"`
+	RotationPeriod *metav1.Duration `json:"rotationPeriod,omitempty"`
+	SecretName     string           `json:"secretName,omitempty"`
+}
+
+type AccessKeyStatus struct {
+	Conditions       []metav1.Condition `json:"conditions"`
+	AccessKey        string             `json:"accessKey,omitempty"`
+	LastRotationTime *metav1.Time       `json:"lastRotationTime,omitempty"`
+}
+
+type AccessKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessKeySpec   `json:"spec,omitempty"`
+	Status AccessKeyStatus `json:"status,omitempty"`
+}
+
+type AccessKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessKey `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessKey{}, &AccessKeyList{})
+}
@@ -1,6 +1,7 @@
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -8,6 +9,8 @@ type BucketSpec struct {
 	Name          string         `json:"name"`
 	ObjectLocking ObjectLocking  `json:"objectLocking,omitempty"`
 	Versioning    VersioningSpec `json:"versioning,omitempty"`
+	// Quota is the hard quota of the bucket size.
+	Quota *resource.Quantity `json:"quota,omitempty"`
 }
 
 type ObjectLocking struct {
@@ -21,7 +24,10 @@ type VersioningSpec struct {
 }
 
 type BucketStatus struct {
-	Conditions []metav1.Condition `json:"conditions"`
+	Conditions   []metav1.Condition `json:"conditions"`
+	NameConflict string             `json:"nameConflict,omitempty"`
+	Bucket       string             `json:"bucket,omitempty"`
+	Quota        *resource.Quantity `json:"quota,omitempty"`
 }
 
 type Bucket struct {
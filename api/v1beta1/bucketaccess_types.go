@@ -0,0 +1,39 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type BucketAccessSpec struct {
+	Bucket string `json:"bucket"`
+	// +kubebuilder:validation:Enum=read;write;readwrite;admin
+	Access     string `json:"access"`
+	Prefix     string `json:"prefix,omitempty"`
+	User       string `json:"user,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type BucketAccessStatus struct {
+	Conditions []metav1.Condition `json:"conditions"`
+	User       string             `json:"user,omitempty"`
+	Policy     string             `json:"policy,omitempty"`
+	Secret     string             `json:"secret,omitempty"`
+}
+
+type BucketAccess struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BucketAccessSpec   `json:"spec,omitempty"`
+	Status BucketAccessStatus `json:"status,omitempty"`
+}
+
+type BucketAccessList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketAccess `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BucketAccess{}, &BucketAccessList{})
+}
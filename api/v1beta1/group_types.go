@@ -0,0 +1,37 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type GroupSpec struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members,omitempty"`
+	Policies []string `json:"policies,omitempty"`
+	Enabled  *bool    `json:"enabled,omitempty"`
+}
+
+type GroupStatus struct {
+	Conditions   []metav1.Condition `json:"conditions"`
+	Members      []string           `json:"members,omitempty"`
+	GroupStatus  string             `json:"groupStatus,omitempty"`
+	NameConflict string             `json:"nameConflict,omitempty"`
+}
+
+type Group struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GroupSpec   `json:"spec,omitempty"`
+	Status GroupStatus `json:"status,omitempty"`
+}
+
+type GroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Group `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Group{}, &GroupList{})
+}
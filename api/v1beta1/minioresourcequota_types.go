@@ -0,0 +1,50 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MinioResourceQuotaSpec caps what a namespace may declare, unset limits are not enforced.
+type MinioResourceQuotaSpec struct {
+	Buckets     *int32             `json:"buckets,omitempty"`
+	Users       *int32             `json:"users,omitempty"`
+	Groups      *int32             `json:"groups,omitempty"`
+	Policies    *int32             `json:"policies,omitempty"`
+	BucketQuota *resource.Quantity `json:"bucketQuota,omitempty"`
+}
+
+// MinioResourceUsage is what a namespace creates in minio: Users include BucketAccess users, Policies
+// include inline, home, BucketAccess and ServiceAccountIdentity policies. BucketQuota is the sum of
+// Bucket quotas, UnlimitedBuckets the number of Buckets without quota.
+type MinioResourceUsage struct {
+	Buckets          int32             `json:"buckets"`
+	Users            int32             `json:"users"`
+	Groups           int32             `json:"groups"`
+	Policies         int32             `json:"policies"`
+	BucketQuota      resource.Quantity `json:"bucketQuota"`
+	UnlimitedBuckets int32             `json:"unlimitedBuckets,omitempty"`
+}
+
+type MinioResourceQuotaStatus struct {
+	Conditions []metav1.Condition `json:"conditions"`
+	Used       MinioResourceUsage `json:"used,omitempty"`
+}
+
+type MinioResourceQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MinioResourceQuotaSpec   `json:"spec,omitempty"`
+	Status MinioResourceQuotaStatus `json:"status,omitempty"`
+}
+
+type MinioResourceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MinioResourceQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MinioResourceQuota{}, &MinioResourceQuotaList{})
+}
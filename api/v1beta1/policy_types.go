@@ -5,12 +5,65 @@ import (
 )
 
 type PolicySpec struct {
-	Name      string `json:"name"`
-	Statement string `json:"statement"`
+	Name          string           `json:"name"`
+	Statement     string           `json:"statement,omitempty"`
+	StatementFrom *StatementSource `json:"statementFrom,omitempty"`
+	Template      bool             `json:"template,omitempty"`
+	Document      *PolicyDocument  `json:"document,omitempty"`
+	Preset        *PolicyPreset    `json:"preset,omitempty"`
+	Include       []string         `json:"include,omitempty"`
+}
+
+type StatementSource struct {
+	ConfigMapKeyRef *ConfigMapKeyReference `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *SecretKeyReference    `json:"secretKeyRef,omitempty"`
+}
+
+type ConfigMapKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type PolicyPreset struct {
+	// +kubebuilder:validation:Enum=readonly;writeonly;readwrite;list-only;consoleAdmin-lite
+	Type string `json:"type"`
+	// +kubebuilder:validation:MinItems=1
+	Buckets []PresetBucket `json:"buckets"`
+}
+
+type PresetBucket struct {
+	Name      string `json:"name,omitempty"`
+	BucketRef string `json:"bucketRef,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+}
+
+type PolicyDocument struct {
+	// +kubebuilder:validation:Enum="2012-10-17"
+	// +kubebuilder:default="2012-10-17"
+	Version string `json:"version,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	Statement []PolicyStatement `json:"statement"`
+}
+
+type PolicyStatement struct {
+	Sid string `json:"sid,omitempty"`
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Effect      string                         `json:"effect"`
+	Action      []string                       `json:"action,omitempty"`
+	NotAction   []string                       `json:"notAction,omitempty"`
+	Resource    []string                       `json:"resource,omitempty"`
+	NotResource []string                       `json:"notResource,omitempty"`
+	Condition   map[string]map[string][]string `json:"condition,omitempty"`
 }
 
 type PolicyStatus struct {
-	Conditions []metav1.Condition `json:"conditions"`
+	Conditions   []metav1.Condition `json:"conditions"`
+	Error        string             `json:"error,omitempty"`
+	Users        []string           `json:"users,omitempty"`
+	Groups       []string           `json:"groups,omitempty"`
+	UserCount    int                `json:"userCount,omitempty"`
+	GroupCount   int                `json:"groupCount,omitempty"`
+	NameConflict string             `json:"nameConflict,omitempty"`
 }
 
 type Policy struct {
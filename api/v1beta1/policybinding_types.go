@@ -0,0 +1,41 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PolicyBindingSpec struct {
+	Policies   []string  `json:"policies,omitempty"`
+	PolicyRefs []string  `json:"policyRefs,omitempty"`
+	Subjects   []Subject `json:"subjects"`
+}
+
+type Subject struct {
+	// +kubebuilder:validation:Enum=User;Group;LDAPUser;LDAPGroup;OIDCClaim
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type PolicyBindingStatus struct {
+	Conditions []metav1.Condition `json:"conditions"`
+	Policies   []string           `json:"policies,omitempty"`
+	Subjects   []Subject          `json:"subjects,omitempty"`
+}
+
+type PolicyBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyBindingSpec   `json:"spec,omitempty"`
+	Status PolicyBindingStatus `json:"status,omitempty"`
+}
+
+type PolicyBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyBinding{}, &PolicyBindingList{})
+}
@@ -0,0 +1,41 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PolicyCheckSpec struct {
+	User       string              `json:"user,omitempty"`
+	Policies   []string            `json:"policies,omitempty"`
+	PolicyRefs []string            `json:"policyRefs,omitempty"`
+	Action     string              `json:"action"`
+	Resource   string              `json:"resource"`
+	Context    map[string][]string `json:"context,omitempty"`
+}
+
+type PolicyCheckStatus struct {
+	Conditions        []metav1.Condition `json:"conditions"`
+	Allowed           bool               `json:"allowed,omitempty"`
+	Decision          string             `json:"decision,omitempty"`
+	MatchedStatements []string           `json:"matchedStatements,omitempty"`
+	Policies          []string           `json:"policies,omitempty"`
+	Error             string             `json:"error,omitempty"`
+}
+
+type PolicyCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyCheckSpec   `json:"spec,omitempty"`
+	Status PolicyCheckStatus `json:"status,omitempty"`
+}
+
+type PolicyCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyCheck{}, &PolicyCheckList{})
+}
@@ -0,0 +1,36 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ServiceAccountIdentitySpec struct {
+	ServiceAccountName string   `json:"serviceAccountName"`
+	PolicyRefs         []string `json:"policyRefs"`
+	Audience           string   `json:"audience,omitempty"`
+}
+
+type ServiceAccountIdentityStatus struct {
+	Conditions     []metav1.Condition `json:"conditions"`
+	Policy         string             `json:"policy,omitempty"`
+	ServiceAccount string             `json:"serviceAccount,omitempty"`
+	ConfigMap      string             `json:"configMap,omitempty"`
+}
+
+type ServiceAccountIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceAccountIdentitySpec   `json:"spec,omitempty"`
+	Status ServiceAccountIdentityStatus `json:"status,omitempty"`
+}
+
+type ServiceAccountIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceAccountIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceAccountIdentity{}, &ServiceAccountIdentityList{})
+}
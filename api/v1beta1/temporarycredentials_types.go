@@ -0,0 +1,36 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type TemporaryCredentialsSpec struct {
+	User       string           `json:"user"`
+	Duration   *metav1.Duration `json:"duration,omitempty"`
+	Policy     string           `json:"policy,omitempty"`
+	SecretName string           `json:"secretName,omitempty"`
+}
+
+type TemporaryCredentialsStatus struct {
+	Conditions         []metav1.Condition `json:"conditions"`
+	ExpiresAt          *metav1.Time       `json:"expiresAt,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+}
+
+type TemporaryCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TemporaryCredentialsSpec   `json:"spec,omitempty"`
+	Status TemporaryCredentialsStatus `json:"status,omitempty"`
+}
+
+type TemporaryCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TemporaryCredentials `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TemporaryCredentials{}, &TemporaryCredentialsList{})
+}
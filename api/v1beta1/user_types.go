@@ -5,12 +5,31 @@ import (
 )
 
 type UserSpec struct {
-	Name     string   `json:"name"`
-	Policies []string `json:"policies,omitempty"`
+	Name              string              `json:"name"`
+	Policies          []string            `json:"policies,omitempty"`
+	PasswordSecretRef *SecretKeyReference `json:"passwordSecretRef,omitempty"`
+	Enabled           *bool               `json:"enabled,omitempty"`
+	Groups            []string            `json:"groups,omitempty"`
+	InlinePolicy      string              `json:"inlinePolicy,omitempty"`
+	Home              *HomeSpec           `json:"home,omitempty"`
+}
+
+type HomeSpec struct {
+	Bucket string `json:"bucket"`
+}
+
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 type UserStatus struct {
-	Conditions []metav1.Condition `json:"conditions"`
+	Conditions    []metav1.Condition `json:"conditions"`
+	AccountStatus string             `json:"accountStatus,omitempty"`
+	Groups        []string           `json:"groups,omitempty"`
+	InlinePolicy  string             `json:"inlinePolicy,omitempty"`
+	Home          string             `json:"home,omitempty"`
+	NameConflict  string             `json:"nameConflict,omitempty"`
 }
 
 type User struct {
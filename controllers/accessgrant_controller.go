@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+// accessGrantMinRequeue keeps a grant requeued when its start or end is less than a second away,
+// as a zero RequeueAfter would not requeue it at all.
+const accessGrantMinRequeue = time.Second
+
+type AccessGrantReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// accessGrantWindow returns the start and the end of the grant, starting at creation if no start is set.
+func accessGrantWindow(grant *pannoiv1beta1.AccessGrant) (time.Time, time.Time) {
+	start := grant.CreationTimestamp.Time
+	if grant.Spec.Start != nil {
+		start = grant.Spec.Start.Time
+	}
+	return start, start.Add(grant.Spec.Duration.Duration)
+}
+
+func accessGrantActive(grant *pannoiv1beta1.AccessGrant, now time.Time) bool {
+	if !grant.DeletionTimestamp.IsZero() {
+		return false
+	}
+	start, end := accessGrantWindow(grant)
+	return !now.Before(start) && now.Before(end)
+}
+
+// accessGrantSchedule returns the phase and remaining time of the grant and when it has to be
+// reconciled again, zero once it is expired. The requeue is rounded up, so the grant is never
+// revisited before its start or end.
+//
+// The remaining time of an active grant is rounded up to hours, or minutes in its last hour, and the
+// grant is revisited when the rounded value changes, so its status is not written every minute.
+func accessGrantSchedule(grant *pannoiv1beta1.AccessGrant, now time.Time) (string, time.Duration, time.Duration) {
+	start, end := accessGrantWindow(grant)
+	switch {
+	case now.Before(start):
+		return "Pending", end.Sub(start), ceilDuration(start.Sub(now))
+	case now.Before(end):
+		remaining := ceilDuration(end.Sub(now))
+		step := time.Minute
+		if remaining > time.Hour {
+			step = time.Hour
+		}
+		shown := remaining.Truncate(step)
+		if shown < remaining {
+			shown += step
+		}
+		return "Active", shown, remaining - (shown - step)
+	default:
+		return "Expired", 0, 0
+	}
+}
+
+// ceilDuration rounds d up to the next second, at least accessGrantMinRequeue.
+func ceilDuration(d time.Duration) time.Duration {
+	if d < accessGrantMinRequeue {
+		return accessGrantMinRequeue
+	}
+	if rounded := d.Truncate(time.Second); rounded < d {
+		return rounded + time.Second
+	}
+	return d
+}
+
+// accessGrantDenied returns why the grant may not be applied, empty if it may. The subject has to be
+// managed by the namespace of the grant and the policy declared by a Policy resource of that namespace
+// and not denied by the guardrails.
+func accessGrantDenied(ctx context.Context, c client.Client, grant *pannoiv1beta1.AccessGrant) (string, error) {
+	subject := grant.Spec.Subject
+	owned, err := subjectOwned(ctx, c, grant.Namespace, subject)
+	if err != nil {
+		return "", err
+	}
+	if !owned {
+		return subject.Kind + " " + subject.Name + " is not managed by a resource in namespace " + grant.Namespace, nil
+	}
+	owned, err = policyOwned(ctx, c, grant.Namespace, grant.Spec.Policy)
+	if err != nil {
+		return "", err
+	}
+	if !owned {
+		return "Policy " + grant.Spec.Policy + " is not declared by a Policy in namespace " + grant.Namespace, nil
+	}
+	return policyReferenceDenied(ctx, c, grant.Namespace, grant.Spec.Policy)
+}
+
+func (r *AccessGrantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	grant := &pannoiv1beta1.AccessGrant{}
+	err := r.Get(ctx, req.NamespacedName, grant)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("AccessGrant resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get AccessGrant resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&grant.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, grant)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	subject := grant.Spec.Subject
+
+	if !grant.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(grant, minioFinalizer) {
+			err = applySubjectPolicies(ctx, r.Client, mc, subject)
+			if err != nil {
+				log.Error(err, "Failed to detach policy from "+subject.Kind+" "+subject.Name)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(grant, minioFinalizer)
+			err = r.Update(ctx, grant)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("AccessGrant was deleted: " + grant.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(grant, minioFinalizer) {
+		controllerutil.AddFinalizer(grant, minioFinalizer)
+		err = r.Update(ctx, grant)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	denied, err := accessGrantDenied(ctx, r.Client, grant)
+	if err != nil {
+		log.Error(err, "Failed to check owner of subject and policy of grant: "+grant.Name)
+		return ctrl.Result{}, err
+	}
+	if denied != "" {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Access denied",
+			Message: denied,
+		}
+		setCondition(&grant.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, grant)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info(denied)
+		return ctrl.Result{}, nil
+	}
+
+	// The schedule is taken before the policies are applied, so a grant expiring in between is
+	// requeued and detached on the next run.
+	phase, remaining, requeue := accessGrantSchedule(grant, time.Now())
+
+	// Policies are recalculated in every phase, so the grant is detached once it is expired.
+	err = applySubjectPolicies(ctx, r.Client, mc, subject)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to attach policy",
+		}
+		setCondition(&grant.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, grant)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update policies of "+subject.Kind+" "+subject.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	_, end := accessGrantWindow(grant)
+	expiresAt := metav1.NewTime(end)
+	grant.Status.ExpiresAt = &expiresAt
+	grant.Status.Phase = phase
+	grant.Status.Remaining = remaining.String()
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	setCondition(&grant.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, grant)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("AccessGrant is " + strings.ToLower(grant.Status.Phase) + ": " + grant.Name)
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+func (r *AccessGrantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.AccessGrant{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestAccessGrantSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	grant := &pannoiv1beta1.AccessGrant{
+		Spec: pannoiv1beta1.AccessGrantSpec{
+			Start:    &metav1.Time{Time: start},
+			Duration: metav1.Duration{Duration: time.Hour},
+		},
+	}
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		phase     string
+		remaining time.Duration
+		requeue   time.Duration
+	}{
+		{"pending", start.Add(-time.Minute), "Pending", time.Hour, time.Minute},
+		{"pending just before start", start.Add(-time.Millisecond), "Pending", time.Hour, time.Second},
+		{"active", start, "Active", time.Hour, time.Minute},
+		{"active within a minute", start.Add(90 * time.Second), "Active", 59 * time.Minute, 30 * time.Second},
+		{"active last seconds", end.Add(-1500 * time.Millisecond), "Active", time.Minute, 2 * time.Second},
+		{"active last milliseconds", end.Add(-300 * time.Millisecond), "Active", time.Minute, time.Second},
+		{"expired at end", end, "Expired", 0, 0},
+		{"expired", end.Add(time.Minute), "Expired", 0, 0},
+	}
+	for _, tt := range tests {
+		phase, remaining, requeue := accessGrantSchedule(grant, tt.now)
+		if phase != tt.phase || remaining != tt.remaining || requeue != tt.requeue {
+			t.Errorf("%s: expected %s with %s left requeued after %s, got %s with %s left after %s",
+				tt.name, tt.phase, tt.remaining, tt.requeue, phase, remaining, requeue)
+		}
+		if active := accessGrantActive(grant, tt.now); active != (tt.phase == "Active") {
+			t.Errorf("%s: expected active %v, got %v", tt.name, tt.phase == "Active", active)
+		}
+	}
+}
+
+func TestAccessGrantScheduleLongGrant(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	grant := &pannoiv1beta1.AccessGrant{
+		Spec: pannoiv1beta1.AccessGrantSpec{
+			Start:    &metav1.Time{Time: start},
+			Duration: metav1.Duration{Duration: 30 * 24 * time.Hour},
+		},
+	}
+
+	writes := 0
+	for now := start; ; writes++ {
+		phase, _, requeue := accessGrantSchedule(grant, now)
+		if phase != "Active" {
+			break
+		}
+		now = now.Add(requeue)
+	}
+	if writes > 30*24+60 {
+		t.Errorf("expected a 30 day grant to be revisited hourly and every minute in its last hour, got %d reconciles", writes)
+	}
+}
+
+func TestAccessGrantDenied(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-a-debug"},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-b-admin"},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		grant   pannoiv1beta1.AccessGrantSpec
+		allowed bool
+	}{
+		{"owned subject and policy", pannoiv1beta1.AccessGrantSpec{Policy: "team-a-debug", Subject: pannoiv1beta1.Subject{Kind: "User", Name: "alice"}}, true},
+		{"foreign subject", pannoiv1beta1.AccessGrantSpec{Policy: "team-a-debug", Subject: pannoiv1beta1.Subject{Kind: "User", Name: "bob"}}, false},
+		{"foreign policy", pannoiv1beta1.AccessGrantSpec{Policy: "team-b-admin", Subject: pannoiv1beta1.Subject{Kind: "User", Name: "alice"}}, false},
+		{"canned policy", pannoiv1beta1.AccessGrantSpec{Policy: "consoleAdmin", Subject: pannoiv1beta1.Subject{Kind: "User", Name: "alice"}}, false},
+	}
+	for _, tt := range tests {
+		grant := &pannoiv1beta1.AccessGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "grant", Namespace: "team-a"},
+			Spec:       tt.grant,
+		}
+		denied, err := accessGrantDenied(context.Background(), c, grant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (denied == "") != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %q", tt.name, tt.allowed, denied)
+		}
+	}
+}
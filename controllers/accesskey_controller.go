@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+const minioFinalizer = "minio-resource-operator.pannoi/finalizer"
+
+type AccessKeyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func (r *AccessKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	accessKey := &pannoiv1beta1.AccessKey{}
+	err := r.Get(ctx, req.NamespacedName, accessKey)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("AccessKey resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get AccessKey resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&accessKey.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, accessKey)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	if !accessKey.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(accessKey, minioFinalizer) {
+			err = deleteServiceAccount(ctx, mc, accessKey.Status.AccessKey)
+			if err != nil {
+				log.Error(err, "Failed to delete service account: "+accessKey.Status.AccessKey)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(accessKey, minioFinalizer)
+			err = r.Update(ctx, accessKey)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("Service account was deleted: " + accessKey.Status.AccessKey)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(accessKey, minioFinalizer) {
+		controllerutil.AddFinalizer(accessKey, minioFinalizer)
+		err = r.Update(ctx, accessKey)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	secretName := accessKey.Spec.SecretName
+	if secretName == "" {
+		secretName = accessKey.Name + "-minio-access-key"
+	}
+
+	if accessKey.Spec.Expiration != nil && !time.Now().Before(accessKey.Spec.Expiration.Time) {
+		if accessKeyExpired(accessKey) {
+			return ctrl.Result{}, nil
+		}
+		err = deleteServiceAccount(ctx, mc, accessKey.Status.AccessKey)
+		if err != nil {
+			log.Error(err, "Failed to delete expired service account: "+accessKey.Status.AccessKey)
+			return ctrl.Result{}, err
+		}
+		err = r.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: req.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete secret: "+secretName)
+			return ctrl.Result{}, err
+		}
+
+		conditions := metav1.Condition{
+			Status: "Expired",
+			Reason: "Expired",
+		}
+		accessKey.Status.AccessKey = ""
+		setCondition(&accessKey.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, accessKey)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Service account expired: " + accessKey.Name)
+		return ctrl.Result{}, nil
+	}
+
+	user := &pannoiv1beta1.User{}
+	err = r.Get(ctx, types.NamespacedName{Name: accessKey.Spec.User, Namespace: req.Namespace}, user)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get parent user",
+		}
+		setCondition(&accessKey.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, accessKey)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get parent user: "+accessKey.Spec.User)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if accessKey.Spec.Policy != "" {
+		err = CheckPolicyGuardrails(ctx, r.Client, req.Namespace, accessKey.Spec.Policy)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Policy violates guardrails",
+				Message: err.Error(),
+			}
+			setCondition(&accessKey.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, accessKey)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Info("Session policy of access key violates guardrails: " + accessKey.Name)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// An empty session policy resets the service account to the policies of its parent user.
+	policy := json.RawMessage(emptySessionPolicy)
+	if accessKey.Spec.Policy != "" {
+		policy = json.RawMessage(accessKey.Spec.Policy)
+	}
+
+	var creds madmin.Credentials
+	if accessKey.Status.AccessKey == "" {
+		id := accessKeyID(accessKey)
+		creds = madmin.Credentials{AccessKey: id}
+
+		creds.SecretKey, err = generatePassword(40)
+		if err == nil {
+			// The service account may be left over by a reconcile which failed to update the status,
+			// it is adopted with a new secret key instead of creating a second one.
+			var info madmin.InfoServiceAccountResp
+			info, err = mc.InfoServiceAccount(ctx, id)
+			switch {
+			case err == nil && info.ParentUser != user.Spec.Name:
+				err = fmt.Errorf("service account %s belongs to user %s", id, info.ParentUser)
+			case err == nil:
+				err = mc.UpdateServiceAccount(ctx, id, madmin.UpdateServiceAccountReq{
+					NewPolicy:    policy,
+					NewSecretKey: creds.SecretKey,
+				})
+			case madmin.ToErrorResponse(err).Code == "XMinioAdminServiceAccountNotFound":
+				_, err = mc.AddServiceAccount(ctx, madmin.AddServiceAccountReq{
+					Policy:     json.RawMessage(accessKey.Spec.Policy),
+					TargetUser: user.Spec.Name,
+					AccessKey:  id,
+					SecretKey:  creds.SecretKey,
+				})
+			}
+		}
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to create service account",
+				Message: err.Error(),
+			}
+			setCondition(&accessKey.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, accessKey)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to create service account for user: "+user.Spec.Name)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	} else {
+		opts := madmin.UpdateServiceAccountReq{NewPolicy: policy}
+		if rotationDue(accessKey) {
+			creds = madmin.Credentials{AccessKey: accessKey.Status.AccessKey}
+			creds.SecretKey, err = generatePassword(40)
+			opts.NewSecretKey = creds.SecretKey
+		}
+
+		if err == nil {
+			err = mc.UpdateServiceAccount(ctx, accessKey.Status.AccessKey, opts)
+		}
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to update service account",
+				Message: err.Error(),
+			}
+			setCondition(&accessKey.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, accessKey)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update service account: "+accessKey.Status.AccessKey)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	if creds.SecretKey != "" {
+		secretMap := make(map[string][]byte)
+		secretMap["accessKey"] = []byte(creds.AccessKey)
+		secretMap["secretKey"] = []byte(creds.SecretKey)
+
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Secret",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: req.Namespace,
+			},
+			Type: corev1.SecretType("generic"),
+			Data: secretMap,
+		}
+
+		_, err = controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+			secret.Data = secretMap
+			return ctrl.SetControllerReference(accessKey, secret, r.Scheme)
+		})
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to create secret",
+			}
+			setCondition(&accessKey.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, accessKey)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to create secret with credentials: "+secretName)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		now := metav1.Now()
+		accessKey.Status.AccessKey = creds.AccessKey
+		accessKey.Status.LastRotationTime = &now
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	setCondition(&accessKey.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, accessKey)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Service account was reconciled: " + accessKey.Status.AccessKey)
+	return ctrl.Result{RequeueAfter: nextAccessKeyEvent(accessKey)}, nil
+}
+
+// emptySessionPolicy has no statements, the service account inherits the policies of its parent user.
+const emptySessionPolicy = `{"Version":"2012-10-17","Statement":[]}`
+
+// accessKeyID returns the access key of the service account, derived from the resource UID when it is
+// not set in the spec, so it stays the same across reconciles.
+func accessKeyID(accessKey *pannoiv1beta1.AccessKey) string {
+	if accessKey.Spec.AccessKey != "" {
+		return accessKey.Spec.AccessKey
+	}
+	id := strings.ToUpper(strings.ReplaceAll(string(accessKey.UID), "-", ""))
+	if len(id) > 18 {
+		id = id[:18]
+	}
+	return "AK" + id
+}
+
+// deleteServiceAccount removes the service account from minio, ignoring ones already gone.
+func deleteServiceAccount(ctx context.Context, mc *madmin.AdminClient, accessKey string) error {
+	if accessKey == "" {
+		return nil
+	}
+	err := mc.DeleteServiceAccount(ctx, accessKey)
+	if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminServiceAccountNotFound" {
+		return err
+	}
+	return nil
+}
+
+// accessKeyExpired reports whether the expiry of the access key was already recorded, its service
+// account and secret are removed then and there is nothing left to do.
+func accessKeyExpired(accessKey *pannoiv1beta1.AccessKey) bool {
+	condition := meta.FindStatusCondition(accessKey.Status.Conditions, readyCondition)
+	return condition != nil && condition.Reason == "Expired" && accessKey.Status.AccessKey == ""
+}
+
+func rotationDue(accessKey *pannoiv1beta1.AccessKey) bool {
+	if accessKey.Spec.RotationPeriod == nil || accessKey.Status.LastRotationTime == nil {
+		return false
+	}
+	return time.Since(accessKey.Status.LastRotationTime.Time) >= accessKey.Spec.RotationPeriod.Duration
+}
+
+// nextAccessKeyEvent returns the time until the next rotation or expiration, zero if none is due.
+func nextAccessKeyEvent(accessKey *pannoiv1beta1.AccessKey) time.Duration {
+	var next time.Duration
+	if accessKey.Spec.RotationPeriod != nil && accessKey.Status.LastRotationTime != nil {
+		next = time.Until(accessKey.Status.LastRotationTime.Add(accessKey.Spec.RotationPeriod.Duration))
+	}
+	if accessKey.Spec.Expiration != nil {
+		untilExpiration := time.Until(accessKey.Spec.Expiration.Time)
+		if next <= 0 || untilExpiration < next {
+			next = untilExpiration
+		}
+	}
+	if next < 0 {
+		return time.Second
+	}
+	return next
+}
+
+func (r *AccessKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.AccessKey{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		password, err := generatePassword(40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 40 {
+			t.Fatalf("expected 40 characters, got %d", len(password))
+		}
+		for _, r := range password {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+				t.Fatalf("unexpected character %q in %s", r, password)
+			}
+		}
+		if seen[password] {
+			t.Fatalf("password %s generated twice", password)
+		}
+		seen[password] = true
+	}
+}
+
+func TestAccessKeyID(t *testing.T) {
+	accessKey := &pannoiv1beta1.AccessKey{}
+	accessKey.UID = "3f1c2a7e-5b6d-4c8e-9f0a-1b2c3d4e5f60"
+
+	id := accessKeyID(accessKey)
+	if id != "AK3F1C2A7E5B6D4C8E9F" {
+		t.Errorf("unexpected access key %s", id)
+	}
+	if len(id) > 20 {
+		t.Errorf("access key %s is longer than 20 characters", id)
+	}
+	if accessKeyID(accessKey) != id {
+		t.Errorf("access key is not stable across calls")
+	}
+
+	accessKey.Spec.AccessKey = "my-key"
+	if id := accessKeyID(accessKey); id != "my-key" {
+		t.Errorf("expected spec access key, got %s", id)
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	rotated := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	accessKey := &pannoiv1beta1.AccessKey{}
+	accessKey.Status.LastRotationTime = &rotated
+
+	if rotationDue(accessKey) {
+		t.Errorf("rotation is due without rotation period")
+	}
+	accessKey.Spec.RotationPeriod = &metav1.Duration{Duration: time.Hour}
+	if !rotationDue(accessKey) {
+		t.Errorf("rotation is not due after the rotation period")
+	}
+	accessKey.Spec.RotationPeriod = &metav1.Duration{Duration: 3 * time.Hour}
+	if rotationDue(accessKey) {
+		t.Errorf("rotation is due before the rotation period")
+	}
+}
+
+func TestAccessKeyExpiredOnce(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "127.0.0.1:1")
+	expiration := metav1.NewTime(time.Now().Add(-time.Hour))
+	accessKey := &pannoiv1beta1.AccessKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "key", Namespace: "team-a", Finalizers: []string{minioFinalizer}},
+		Spec:       pannoiv1beta1.AccessKeySpec{User: "user", Expiration: &expiration},
+	}
+	setCondition(&accessKey.Status.Conditions, metav1.Condition{Status: "Expired", Reason: "Expired"})
+	c := newFakeClient(accessKey)
+	r := &AccessKeyReconciler{Client: c}
+	key := types.NamespacedName{Name: "key", Namespace: "team-a"}
+
+	before := &pannoiv1beta1.AccessKey{}
+	if err := c.Get(context.Background(), key, before); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("expected a recorded expiry not to reach minio, got %v", err)
+	}
+	if result.Requeue || result.RequeueAfter != 0 {
+		t.Errorf("expected an expired access key not to be requeued, got %+v", result)
+	}
+	after := &pannoiv1beta1.AccessKey{}
+	if err := c.Get(context.Background(), key, after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion != before.ResourceVersion {
+		t.Error("expected the status of an expired access key not to be written again")
+	}
+}
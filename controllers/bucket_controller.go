@@ -6,24 +6,121 @@ import (
 	"os"
 	"strings"
 
+	"github.com/minio/madmin-go"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// specNameField indexes Buckets, Users, Policies and Groups by their minio name.
+const specNameField = ".spec.name"
+
+// BucketName returns the minio bucket of the Bucket: status.bucket once it is recorded, spec.name otherwise.
+func BucketName(bucket *pannoiv1beta1.Bucket) string {
+	if bucket.Status.Bucket != "" {
+		return bucket.Status.Bucket
+	}
+	return bucket.Spec.Name
+}
+
+// bucketNames returns the minio names claimed by the Bucket, spec.name and the bucket kept from
+// a previous version.
+func bucketNames(bucket *pannoiv1beta1.Bucket) []string {
+	if bucket.Status.Bucket != "" && bucket.Status.Bucket != bucket.Spec.Name {
+		return []string{bucket.Spec.Name, bucket.Status.Bucket}
+	}
+	return []string{bucket.Spec.Name}
+}
+
 type BucketReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 }
 
+// claimsBefore orders objects claiming the same minio name, the oldest one owns it.
+func claimsBefore(a, b client.Object) bool {
+	at, bt := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !at.Equal(&bt) {
+		return at.Before(&bt)
+	}
+	return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+}
+
+// NameClaimant returns the object of the list owning the minio name, nil if it is not claimed.
+func NameClaimant(ctx context.Context, c client.Client, list client.ObjectList, name string) (client.Object, error) {
+	err := c.List(ctx, list, client.MatchingFields{specNameField: name})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimant client.Object
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if ok && (claimant == nil || claimsBefore(obj, claimant)) {
+			claimant = obj
+		}
+	}
+	return claimant, nil
+}
+
+// conflictingClaim returns the object owning the minio name of obj as "namespace/name",
+// empty if obj owns it.
+func conflictingClaim(ctx context.Context, c client.Client, list client.ObjectList, obj client.Object, name string) (string, error) {
+	claimant, err := NameClaimant(ctx, c, list, name)
+	if err != nil || claimant == nil {
+		return "", err
+	}
+	if claimant.GetNamespace() == obj.GetNamespace() && claimant.GetName() == obj.GetName() {
+		return "", nil
+	}
+	return claimant.GetNamespace() + "/" + claimant.GetName(), nil
+}
+
+// sameNameRequests returns requests for the objects of the list sharing the minio name, except obj.
+func sameNameRequests(ctx context.Context, c client.Client, list client.ObjectList, obj client.Object, name string) []reconcile.Request {
+	err := c.List(ctx, list, client.MatchingFields{specNameField: name})
+	if err != nil {
+		return []reconcile.Request{}
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range items {
+		other, ok := item.(client.Object)
+		if !ok || (other.GetNamespace() == obj.GetNamespace() && other.GetName() == obj.GetName()) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      other.GetName(),
+				Namespace: other.GetNamespace(),
+			},
+		})
+	}
+	return requests
+}
+
 func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
@@ -55,8 +152,8 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			Status: "Failed",
 			Reason: "Failed to connect to Minio",
 		}
-		bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, bucket)
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
 		if err != nil {
 			log.Error(err, "Failed to update Bucket status")
 			return ctrl.Result{}, err
@@ -65,40 +162,162 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
-	found, err := mc.BucketExists(ctx, bucket.Spec.Name)
+	adm, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
 	if err != nil {
 		conditions := metav1.Condition{
 			Status: "Failed",
 			Reason: "Failed to connect to Minio",
 		}
-		bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, bucket)
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
 		if err != nil {
 			log.Error(err, "Failed to update Bucket status")
 			return ctrl.Result{}, err
 		}
-		log.Error(err, "Cannot check if bucket exists")
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
 		return ctrl.Result{}, err
 	}
-	if found {
-		log.Info("Bucket already exists")
-		return ctrl.Result{Requeue: false}, err
+
+	claimant, err := conflictingClaim(ctx, r.Client, &pannoiv1beta1.BucketList{}, bucket, bucket.Spec.Name)
+	if err != nil {
+		log.Error(err, "Failed to check claims of bucket name: "+bucket.Spec.Name)
+		return ctrl.Result{}, err
+	}
+	if claimant != "" {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NameConflict",
+			Message: "Bucket name " + bucket.Spec.Name + " is claimed by " + claimant,
+		}
+		bucket.Status.NameConflict = claimant
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+		log.Info("Bucket name " + bucket.Spec.Name + " is claimed by " + claimant)
+		return ctrl.Result{}, nil
+	}
+	if bucket.Status.NameConflict != "" {
+		bucket.Status.NameConflict = ""
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = CheckNamespaceName(ctx, r.Client, bucket.Namespace, "bucket", bucket.Spec.Name)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NamespacePrefix",
+			Message: err.Error(),
+		}
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+		log.Info("Bucket name is not prefixed with namespace prefix: " + bucket.Spec.Name)
+		return ctrl.Result{}, nil
 	}
 
-	err = mc.MakeBucket(context.Background(), bucket.Name, minio.MakeBucketOptions{ObjectLocking: bucket.Spec.ObjectLocking.Enabled})
+	bucketName, err := r.resolveBucketName(ctx, bucket, mc.BucketExists)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed to connect to Minio",
+			Message: err.Error(),
+		}
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Cannot resolve bucket name")
+		return ctrl.Result{}, err
+	}
+	if bucket.Status.Bucket != bucketName {
+		bucket.Status.Bucket = bucketName
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	found, err := mc.BucketExists(ctx, bucketName)
 	if err != nil {
 		conditions := metav1.Condition{
 			Status: "Failed",
-			Reason: "Failed to create bucket",
+			Reason: "Failed to connect to Minio",
 		}
-		bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, bucket)
+		setCondition(&bucket.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, bucket)
 		if err != nil {
 			log.Error(err, "Failed to update Bucket status")
 			return ctrl.Result{}, err
 		}
-		log.Error(err, "Failed to create bucket: "+bucket.Spec.Name)
-		return ctrl.Result{Requeue: true}, err
+		log.Error(err, "Cannot check if bucket exists")
+		return ctrl.Result{}, err
+	}
+	if !found {
+		err = mc.MakeBucket(context.Background(), bucketName, minio.MakeBucketOptions{ObjectLocking: bucket.Spec.ObjectLocking.Enabled})
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to create bucket",
+			}
+			setCondition(&bucket.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, bucket)
+			if err != nil {
+				log.Error(err, "Failed to update Bucket status")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to create bucket: "+bucketName)
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	// The quota follows the spec of existing buckets as well.
+	quota := bucketQuota(bucket)
+	if quota != nil {
+		err = adm.SetBucketQuota(ctx, bucketName, quota)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to set bucket quota",
+				Message: err.Error(),
+			}
+			setCondition(&bucket.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, bucket)
+			if err != nil {
+				log.Error(err, "Failed to update Bucket status")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to set bucket quota: "+bucketName)
+			return ctrl.Result{Requeue: true}, nil
+		}
+		bucket.Status.Quota = bucket.Spec.Quota
+		err = updateStatus(ctx, r.Client, bucket)
+		if err != nil {
+			log.Error(err, "Failed to update Bucket status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if found {
+		log.Info("Bucket already exists")
+		return ctrl.Result{Requeue: false}, err
 	}
 
 	if bucket.Spec.ObjectLocking.Enabled {
@@ -115,37 +334,37 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			retentionMode = minio.Governance
 		}
 
-		err = mc.SetObjectLockConfig(ctx, bucket.Spec.Name, &retentionMode, &retentionPeriod, &validityUnit)
+		err = mc.SetObjectLockConfig(ctx, bucketName, &retentionMode, &retentionPeriod, &validityUnit)
 		if err != nil {
 			conditions := metav1.Condition{
 				Status: "Failed",
 				Reason: "Failed to enable object locking",
 			}
-			bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-			err = r.Status().Update(ctx, bucket)
+			setCondition(&bucket.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, bucket)
 			if err != nil {
 				log.Error(err, "Failed to update Bucket status")
 				return ctrl.Result{}, err
 			}
-			log.Error(err, "Failed to enable object locking for: "+bucket.Spec.Name)
+			log.Error(err, "Failed to enable object locking for: "+bucketName)
 			return ctrl.Result{}, nil
 		}
 	}
 
 	if bucket.Spec.Versioning.Enabled {
-		err = mc.EnableVersioning(ctx, bucket.Spec.Name)
+		err = mc.EnableVersioning(ctx, bucketName)
 		if err != nil {
 			conditions := metav1.Condition{
 				Status: "Failed",
 				Reason: "Failed to enable bucket versioning",
 			}
-			bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-			err = r.Status().Update(ctx, bucket)
+			setCondition(&bucket.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, bucket)
 			if err != nil {
 				log.Error(err, "Failed to update Bucket status")
 				return ctrl.Result{}, err
 			}
-			log.Error(err, "Failed to enable bucket versioning: "+bucket.Spec.Name)
+			log.Error(err, "Failed to enable bucket versioning: "+bucketName)
 			return ctrl.Result{}, err
 		}
 	}
@@ -154,19 +373,75 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		Status: "Ready",
 		Reason: "Ready",
 	}
-	bucket.Status.Conditions = append(bucket.Status.Conditions, conditions)
-	err = r.Status().Update(ctx, bucket)
+	setCondition(&bucket.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, bucket)
 	if err != nil {
 		log.Error(err, "Failed to update Bucket status")
 		return ctrl.Result{}, err
 	}
 
-	log.Info("Minio bucket was created: " + bucket.Spec.Name)
+	log.Info("Minio bucket was created: " + bucketName)
 	return ctrl.Result{}, nil
 }
 
+// bucketQuota returns the quota to set on the bucket: spec.quota, an empty quota clearing the one
+// recorded in status.quota once it is removed from the spec, nil to leave quotas set by admins as they are.
+func bucketQuota(bucket *pannoiv1beta1.Bucket) *madmin.BucketQuota {
+	if bucket.Spec.Quota != nil {
+		return &madmin.BucketQuota{Quota: uint64(bucket.Spec.Quota.Value()), Type: madmin.HardQuota}
+	}
+	if bucket.Status.Quota != nil {
+		return &madmin.BucketQuota{}
+	}
+	return nil
+}
+
+// resolveBucketName returns the minio bucket of the Bucket. Buckets reconciled by earlier versions,
+// which created the bucket with metadata.name, keep that bucket as long as no bucket exists with
+// spec.name and no other Bucket claims metadata.name.
+func (r *BucketReconciler) resolveBucketName(ctx context.Context, bucket *pannoiv1beta1.Bucket, exists func(context.Context, string) (bool, error)) (string, error) {
+	if bucket.Status.Bucket != "" {
+		return bucket.Status.Bucket, nil
+	}
+	if bucket.Name == bucket.Spec.Name || len(bucket.Status.Conditions) == 0 {
+		return bucket.Spec.Name, nil
+	}
+
+	found, err := exists(ctx, bucket.Spec.Name)
+	if err != nil || found {
+		return bucket.Spec.Name, err
+	}
+	found, err = exists(ctx, bucket.Name)
+	if err != nil || !found {
+		return bucket.Spec.Name, err
+	}
+	claimant, err := conflictingClaim(ctx, r.Client, &pannoiv1beta1.BucketList{}, bucket, bucket.Name)
+	if err != nil || claimant != "" {
+		return bucket.Spec.Name, err
+	}
+	return bucket.Name, nil
+}
+
+// findBucketsWithSameName maps a Bucket to the other Buckets claiming one of its minio names.
+func (r *BucketReconciler) findBucketsWithSameName(ctx context.Context, obj client.Object) []reconcile.Request {
+	bucket := obj.(*pannoiv1beta1.Bucket)
+	requests := []reconcile.Request{}
+	for _, name := range bucketNames(bucket) {
+		requests = append(requests, sameNameRequests(ctx, r.Client, &pannoiv1beta1.BucketList{}, bucket, name)...)
+	}
+	return requests
+}
+
 func (r *BucketReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.Bucket{}, specNameField, func(obj client.Object) []string {
+		return bucketNames(obj.(*pannoiv1beta1.Bucket))
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&pannoiv1beta1.Bucket{}).
+		For(&pannoiv1beta1.Bucket{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Bucket{}, handler.EnqueueRequestsFromMapFunc(r.findBucketsWithSameName)).
 		Complete(r)
 }
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestResolveBucketName(t *testing.T) {
+	legacy := func(name, specName string) *pannoiv1beta1.Bucket {
+		bucket := &pannoiv1beta1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketSpec{Name: specName},
+		}
+		setCondition(&bucket.Status.Conditions, metav1.Condition{Status: "Ready", Reason: "Ready"})
+		return bucket
+	}
+	recorded := legacy("data", "team-a-data")
+	recorded.Status.Bucket = "data"
+	fresh := legacy("data", "team-a-data")
+	fresh.Status.Conditions = nil
+
+	tests := []struct {
+		name     string
+		bucket   *pannoiv1beta1.Bucket
+		existing []string
+		expected string
+	}{
+		{"recorded bucket", recorded, []string{"team-a-data"}, "data"},
+		{"new bucket", fresh, []string{"data"}, "team-a-data"},
+		{"legacy bucket", legacy("data", "team-a-data"), []string{"data"}, "data"},
+		{"legacy bucket with spec name bucket", legacy("data", "team-a-data"), []string{"data", "team-a-data"}, "team-a-data"},
+		{"legacy bucket without bucket", legacy("data", "team-a-data"), nil, "team-a-data"},
+		{"legacy bucket claimed by another", legacy("logs", "team-a-logs"), []string{"logs"}, "team-a-logs"},
+	}
+	for _, tt := range tests {
+		r := &BucketReconciler{Client: newFakeClient(
+			tt.bucket,
+			&pannoiv1beta1.Bucket{
+				ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "team-b", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+				Spec:       pannoiv1beta1.BucketSpec{Name: "logs"},
+			},
+		)}
+		exists := func(ctx context.Context, name string) (bool, error) {
+			return containsString(tt.existing, name), nil
+		}
+
+		name, err := r.resolveBucketName(context.Background(), tt.bucket, exists)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != tt.expected {
+			t.Errorf("%s: expected bucket %s, got %s", tt.name, tt.expected, name)
+		}
+	}
+}
+
+func TestBucketNameIndex(t *testing.T) {
+	bucket := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-data"},
+	}
+	bucket.Status.Bucket = "data"
+	c := newFakeClient(bucket)
+
+	claimant, err := NameClaimant(context.Background(), c, &pannoiv1beta1.BucketList{}, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimant == nil || claimant.GetName() != "data" {
+		t.Errorf("expected the recorded bucket to stay claimed, got %v", claimant)
+	}
+	if name := BucketName(bucket); name != "data" {
+		t.Errorf("expected the recorded bucket, got %s", name)
+	}
+}
+
+func TestBucketQuota(t *testing.T) {
+	quota := resource.MustParse("10Gi")
+	bucket := &pannoiv1beta1.Bucket{}
+
+	if q := bucketQuota(bucket); q != nil {
+		t.Errorf("expected the quota of a bucket without spec.quota to be left alone, got %+v", q)
+	}
+	bucket.Spec.Quota = &quota
+	if q := bucketQuota(bucket); q == nil || q.Quota != uint64(quota.Value()) || q.Type != madmin.HardQuota {
+		t.Errorf("expected a hard quota of 10Gi, got %+v", q)
+	}
+	bucket.Spec.Quota = nil
+	bucket.Status.Quota = &quota
+	if q := bucketQuota(bucket); q == nil || q.Quota != 0 {
+		t.Errorf("expected a removed quota to be cleared, got %+v", q)
+	}
+}
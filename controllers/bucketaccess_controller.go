@@ -0,0 +1,492 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+type BucketAccessReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// bucketAccessStatement is a single statement of the generated bucket access policy.
+type bucketAccessStatement struct {
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// bucketAdminObjectActions and bucketAdminBucketActions are granted by the admin access level. They cover
+// objects and bucket configuration, but not deleting the bucket, its policy or replication.
+var (
+	bucketAdminObjectActions = []string{
+		"s3:GetObject", "s3:PutObject", "s3:DeleteObject",
+		"s3:GetObjectVersion", "s3:DeleteObjectVersion",
+		"s3:GetObjectTagging", "s3:PutObjectTagging", "s3:DeleteObjectTagging",
+		"s3:GetObjectRetention", "s3:PutObjectRetention",
+		"s3:GetObjectLegalHold", "s3:PutObjectLegalHold",
+		"s3:AbortMultipartUpload", "s3:ListMultipartUploadParts",
+	}
+	bucketAdminBucketActions = []string{
+		"s3:ListBucket", "s3:ListBucketVersions", "s3:ListBucketMultipartUploads",
+		"s3:GetBucketVersioning", "s3:PutBucketVersioning",
+		"s3:GetLifecycleConfiguration", "s3:PutLifecycleConfiguration",
+		"s3:GetBucketNotification", "s3:PutBucketNotification",
+		"s3:GetBucketTagging", "s3:PutBucketTagging",
+		"s3:GetEncryptionConfiguration", "s3:PutEncryptionConfiguration",
+		"s3:GetBucketObjectLockConfiguration", "s3:PutBucketObjectLockConfiguration",
+	}
+)
+
+// bucketAccessUser returns the minio user of the access. Without spec.user it is named after the
+// resource, qualified with the namespace as minio users are cluster-wide.
+func bucketAccessUser(access *pannoiv1beta1.BucketAccess) string {
+	if access.Spec.User != "" {
+		return access.Spec.User
+	}
+	return access.Namespace + "." + access.Name
+}
+
+// BucketAccessSecret returns the name of the secret with the credentials of the access.
+func BucketAccessSecret(access *pannoiv1beta1.BucketAccess) string {
+	if access.Spec.SecretName != "" {
+		return access.Spec.SecretName
+	}
+	if access.Spec.User != "" {
+		return access.Spec.User + "-minio-credentials"
+	}
+	return access.Name + "-minio-credentials"
+}
+
+// UserNameClaimant returns the oldest User or BucketAccess managing the minio user, nil if it is not claimed.
+func UserNameClaimant(ctx context.Context, c client.Client, username string) (client.Object, error) {
+	claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.UserList{}, username)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses := &pannoiv1beta1.BucketAccessList{}
+	err = c.List(ctx, accesses)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accesses.Items {
+		access := &accesses.Items[i]
+		if bucketAccessUser(access) == username && (claimant == nil || claimsBefore(access, claimant)) {
+			claimant = access
+		}
+	}
+	return claimant, nil
+}
+
+// bucketAccessConflict returns why the access may not manage its user and policy, empty if it may.
+// The user must not be managed by another resource and the access-<user> policy must not be declared
+// by a Policy resource.
+func bucketAccessConflict(ctx context.Context, c client.Client, access *pannoiv1beta1.BucketAccess) (string, error) {
+	username := bucketAccessUser(access)
+	claimant, err := UserNameClaimant(ctx, c, username)
+	if err != nil {
+		return "", err
+	}
+	if claimant != nil {
+		owner, ok := claimant.(*pannoiv1beta1.BucketAccess)
+		if !ok || owner.Namespace != access.Namespace || owner.Name != access.Name {
+			return "User name " + username + " is claimed by " + claimant.GetNamespace() + "/" + claimant.GetName(), nil
+		}
+	}
+
+	policyName := bucketAccessPolicyName(username)
+	claimant, err = NameClaimant(ctx, c, &pannoiv1beta1.PolicyList{}, policyName)
+	if err != nil {
+		return "", err
+	}
+	if claimant != nil {
+		return "Policy name " + policyName + " is claimed by " + claimant.GetNamespace() + "/" + claimant.GetName(), nil
+	}
+	return "", nil
+}
+
+func bucketAccessPolicyName(username string) string {
+	return "access-" + username
+}
+
+// bucketAccessPolicy generates the least-privilege policy for the access level on the bucket,
+// limited to the prefix when it is set.
+func bucketAccessPolicy(bucket, prefix, access string) (string, error) {
+	bucketArn := "arn:aws:s3:::" + bucket
+	objectArn := bucketArn + "/*"
+
+	var listCondition map[string]map[string][]string
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		objectArn = bucketArn + "/" + prefix + "/*"
+		listCondition = map[string]map[string][]string{
+			"StringLike": {"s3:prefix": {"", prefix + "/", prefix + "/*"}},
+		}
+	}
+
+	statements := []bucketAccessStatement{{
+		Effect:   "Allow",
+		Action:   []string{"s3:GetBucketLocation"},
+		Resource: []string{bucketArn},
+	}}
+
+	if access == "admin" && prefix == "" {
+		statements = append(statements, bucketAccessStatement{
+			Effect:   "Allow",
+			Action:   bucketAdminBucketActions,
+			Resource: []string{bucketArn},
+		}, bucketAccessStatement{
+			Effect:   "Allow",
+			Action:   bucketAdminObjectActions,
+			Resource: []string{objectArn},
+		})
+	} else {
+		var objectActions []string
+		if access == "read" || access == "readwrite" || access == "admin" {
+			statements = append(statements, bucketAccessStatement{
+				Effect:    "Allow",
+				Action:    []string{"s3:ListBucket"},
+				Resource:  []string{bucketArn},
+				Condition: listCondition,
+			})
+			objectActions = append(objectActions, "s3:GetObject")
+		}
+		if access == "write" || access == "readwrite" || access == "admin" {
+			statements = append(statements, bucketAccessStatement{
+				Effect:   "Allow",
+				Action:   []string{"s3:ListBucketMultipartUploads"},
+				Resource: []string{bucketArn},
+			})
+			objectActions = append(objectActions,
+				"s3:PutObject",
+				"s3:DeleteObject",
+				"s3:AbortMultipartUpload",
+				"s3:ListMultipartUploadParts",
+			)
+		}
+		if access == "admin" {
+			objectActions = bucketAdminObjectActions
+		}
+		statements = append(statements, bucketAccessStatement{
+			Effect:   "Allow",
+			Action:   objectActions,
+			Resource: []string{objectArn},
+		})
+	}
+
+	out, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (r *BucketAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	access := &pannoiv1beta1.BucketAccess{}
+	err := r.Get(ctx, req.NamespacedName, access)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("BucketAccess resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get BucketAccess resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	if !access.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(access, minioFinalizer) {
+			if access.Status.User != "" {
+				err = mc.RemoveUser(ctx, access.Status.User)
+				if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminNoSuchUser" {
+					log.Error(err, "Failed to remove user: "+access.Status.User)
+					return ctrl.Result{}, err
+				}
+			}
+			err = removeCannedPolicy(ctx, mc, access.Status.Policy)
+			if err != nil {
+				log.Error(err, "Failed to remove policy: "+access.Status.Policy)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(access, minioFinalizer)
+			err = r.Update(ctx, access)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("BucketAccess was deleted: " + access.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(access, minioFinalizer) {
+		controllerutil.AddFinalizer(access, minioFinalizer)
+		err = r.Update(ctx, access)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	bucket := &pannoiv1beta1.Bucket{}
+	err = r.Get(ctx, types.NamespacedName{Name: access.Spec.Bucket, Namespace: req.Namespace}, bucket)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get bucket",
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get bucket: "+access.Spec.Bucket)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	username := bucketAccessUser(access)
+	policyName := bucketAccessPolicyName(username)
+	secretName := BucketAccessSecret(access)
+
+	conflict, err := bucketAccessConflict(ctx, r.Client, access)
+	if err == nil && conflict == "" {
+		err = CheckNamespaceName(ctx, r.Client, access.Namespace, "user", username)
+		if err != nil {
+			conflict, err = err.Error(), nil
+		}
+	}
+	if err != nil {
+		log.Error(err, "Failed to check claims of user name: "+username)
+		return ctrl.Result{}, err
+	}
+	if conflict != "" {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NameConflict",
+			Message: conflict,
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info(conflict)
+		return ctrl.Result{}, nil
+	}
+
+	policy, err := bucketAccessPolicy(BucketName(bucket), access.Spec.Prefix, access.Spec.Access)
+	if err == nil {
+		err = mc.AddCannedPolicy(ctx, policyName, []byte(policy))
+	}
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed create policy",
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create policy: "+policyName)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// The password stored in the secret is kept, so the credentials survive reconciliation.
+	var password string
+	found := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: req.Namespace}, found)
+	owned := err == nil && metav1.IsControlledBy(found, access) && string(found.Data["accessKey"]) == username
+	if owned && len(found.Data["secretKey"]) > 0 {
+		password = string(found.Data["secretKey"])
+	} else {
+		var genErr error
+		password, genErr = generatePassword(20)
+		if genErr != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to generate password",
+				Message: genErr.Error(),
+			}
+			setCondition(&access.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, access)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(genErr, "Failed to generate password for user: "+username)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	// A minio user which is neither recorded in status nor in the secret of the access was not created
+	// by it, so it is not taken over.
+	if access.Status.User != username && !owned {
+		_, err = mc.GetUserInfo(ctx, username)
+		if err == nil {
+			err = fmt.Errorf("user %s already exists in minio", username)
+		} else if madmin.ToErrorResponse(err).Code == "XMinioAdminNoSuchUser" {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = mc.AddUser(ctx, username, password)
+	}
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed to create user in minio",
+			Message: err.Error(),
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create user: "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: req.Namespace,
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Type = corev1.SecretType("generic")
+		secret.Data = map[string][]byte{
+			"accessKey": []byte(username),
+			"secretKey": []byte(password),
+		}
+		return ctrl.SetControllerReference(access, secret, r.Scheme)
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to create secret",
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create secret with credentials: "+secretName)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	err = applySubjectPolicies(ctx, r.Client, mc, pannoiv1beta1.Subject{Kind: "User", Name: username})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to attach policy",
+		}
+		setCondition(&access.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, access)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to attach policy "+policyName+" to user "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// A renamed user is a new identity, the previous one is removed with its policy.
+	if access.Status.User != "" && access.Status.User != username {
+		err = mc.RemoveUser(ctx, access.Status.User)
+		if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminNoSuchUser" {
+			log.Error(err, "Failed to remove previous user: "+access.Status.User)
+			return ctrl.Result{Requeue: true}, nil
+		}
+		err = removeCannedPolicy(ctx, mc, access.Status.Policy)
+		if err != nil {
+			log.Error(err, "Failed to remove previous policy: "+access.Status.Policy)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	access.Status.User = username
+	access.Status.Policy = policyName
+	access.Status.Secret = secretName
+	setCondition(&access.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, access)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("BucketAccess was reconciled: " + access.Name)
+	return ctrl.Result{}, nil
+}
+
+func (r *BucketAccessReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.BucketAccess{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Secret{}, builder.OnlyMetadata).
+		Complete(r)
+}
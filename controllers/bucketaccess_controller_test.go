@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestBucketAccessUser(t *testing.T) {
+	access := &pannoiv1beta1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}}
+	if user := bucketAccessUser(access); user != "team-a.app" {
+		t.Errorf("expected namespace qualified user, got %s", user)
+	}
+	if secret := BucketAccessSecret(access); secret != "app-minio-credentials" {
+		t.Errorf("expected secret named after the resource, got %s", secret)
+	}
+
+	access.Spec.User = "reporting"
+	if user := bucketAccessUser(access); user != "reporting" {
+		t.Errorf("expected spec.user, got %s", user)
+	}
+	if secret := BucketAccessSecret(access); secret != "reporting-minio-credentials" {
+		t.Errorf("expected secret named after the user, got %s", secret)
+	}
+}
+
+func TestBucketAccessPolicyAdmin(t *testing.T) {
+	for _, prefix := range []string{"", "data/"} {
+		policy, err := bucketAccessPolicy("team-a-data", prefix, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(policy, `"s3:*"`) || strings.Contains(policy, "s3:DeleteBucket") || strings.Contains(policy, "BucketPolicy") {
+			t.Errorf("prefix %q: expected admin to be limited to object and bucket configuration actions, got %s", prefix, policy)
+		}
+		if !strings.Contains(policy, "s3:PutObject") {
+			t.Errorf("prefix %q: expected object actions, got %s", prefix, policy)
+		}
+		document, err := ParsePolicyDocument(policy)
+		if err == nil {
+			err = ValidatePolicyDocument(document)
+		}
+		if err != nil {
+			t.Errorf("prefix %q: expected valid policy, got %v", prefix, err)
+		}
+	}
+}
+
+func TestBucketAccessConflict(t *testing.T) {
+	older := metav1.NewTime(time.Now().Add(-time.Hour))
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "team-b", CreationTimestamp: older},
+			Spec:       pannoiv1beta1.UserSpec{Name: "admin"},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "access", Namespace: "team-b", CreationTimestamp: older},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "access-team-a.report"},
+		},
+		&pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "team-a", CreationTimestamp: older},
+			Spec:       pannoiv1beta1.BucketAccessSpec{User: "shared"},
+		},
+	)
+
+	tests := []struct {
+		name   string
+		access *pannoiv1beta1.BucketAccess
+		free   bool
+	}{
+		{"own user", &pannoiv1beta1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}}, true},
+		{"user of a User resource", &pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketAccessSpec{User: "admin"},
+		}, false},
+		{"user of an older access", &pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketAccessSpec{User: "shared"},
+		}, false},
+		{"claimant access", &pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "team-a", CreationTimestamp: older},
+			Spec:       pannoiv1beta1.BucketAccessSpec{User: "shared"},
+		}, true},
+		{"policy of a Policy resource", &pannoiv1beta1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "team-a"}}, false},
+	}
+	for _, tt := range tests {
+		conflict, err := bucketAccessConflict(context.Background(), c, tt.access)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (conflict == "") != tt.free {
+			t.Errorf("%s: expected free %v, got %q", tt.name, tt.free, conflict)
+		}
+	}
+}
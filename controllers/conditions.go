@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// readyCondition is the type of the condition reporting the outcome of the last reconcile.
+const readyCondition = "Ready"
+
+// setCondition records the outcome of a reconcile as the Ready condition, replacing the previous one,
+// so the conditions do not grow with every reconcile and an unchanged outcome keeps its transition time.
+func setCondition(conditions *[]metav1.Condition, condition metav1.Condition) {
+	condition.Type = readyCondition
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// updateStatus writes the status of the object unless it equals the status of the cached object, so a
+// reconcile which changes nothing does not bump the resourceVersion and trigger another reconcile.
+func updateStatus(ctx context.Context, c client.Client, obj client.Object) error {
+	current := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err == nil {
+		equal, err := statusEqual(obj, current)
+		if err != nil {
+			return err
+		}
+		if equal {
+			return nil
+		}
+	}
+	return c.Status().Update(ctx, obj)
+}
+
+func statusEqual(a, b client.Object) (bool, error) {
+	ua, err := runtime.DefaultUnstructuredConverter.ToUnstructured(a)
+	if err != nil {
+		return false, err
+	}
+	ub, err := runtime.DefaultUnstructuredConverter.ToUnstructured(b)
+	if err != nil {
+		return false, err
+	}
+	return equality.Semantic.DeepEqual(ua["status"], ub["status"]), nil
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestSetCondition(t *testing.T) {
+	var conditions []metav1.Condition
+	setCondition(&conditions, metav1.Condition{Status: "Failed", Reason: "NameConflict"})
+	setCondition(&conditions, metav1.Condition{Status: "Ready", Reason: "Ready"})
+	if len(conditions) != 1 || conditions[0].Type != readyCondition || conditions[0].Reason != "Ready" {
+		t.Errorf("expected a single Ready condition, got %+v", conditions)
+	}
+}
+
+func TestReconcileKeepsOneCondition(t *testing.T) {
+	users := int32(5)
+	c := newFakeClient(
+		&pannoiv1beta1.MinioResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.MinioResourceQuotaSpec{Users: &users},
+		},
+	)
+	r := &MinioResourceQuotaReconciler{Client: c}
+	key := types.NamespacedName{Name: "quota", Namespace: "team-a"}
+
+	var resourceVersion string
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		quota := &pannoiv1beta1.MinioResourceQuota{}
+		err = c.Get(context.Background(), key, quota)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(quota.Status.Conditions) != 1 {
+			t.Errorf("reconcile %d: expected one condition, got %d", i+1, len(quota.Status.Conditions))
+		}
+		if i == 1 && quota.ResourceVersion != resourceVersion {
+			t.Error("expected an unchanged status not to be written again")
+		}
+		resourceVersion = quota.ResourceVersion
+	}
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// TestCRDStatusConditions makes sure the conditions written by the controllers are not pruned by the chart CRDs.
+func TestCRDStatusConditions(t *testing.T) {
+	files, err := filepath.Glob("../chart/crd/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no CRDs found in chart/crd")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		err = yaml.Unmarshal(data, crd)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		for _, version := range crd.Spec.Versions {
+			status := version.Schema.OpenAPIV3Schema.Properties["status"]
+			conditions, ok := status.Properties["conditions"]
+			if !ok || conditions.Items == nil || conditions.Items.Schema == nil ||
+				conditions.Items.Schema.XPreserveUnknownFields == nil || !*conditions.Items.Schema.XPreserveUnknownFields {
+				t.Errorf("%s %s: status.conditions is missing or pruned", crd.Name, version.Name)
+			}
+		}
+	}
+}
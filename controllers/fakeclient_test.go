@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+// newFakeClient returns a client serving the objects, with the field indexes of the controllers.
+func newFakeClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pannoiv1beta1.AddToScheme(scheme)
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(
+			&pannoiv1beta1.AccessGrant{}, &pannoiv1beta1.AccessKey{}, &pannoiv1beta1.Bucket{},
+			&pannoiv1beta1.BucketAccess{}, &pannoiv1beta1.Group{}, &pannoiv1beta1.MinioResourceQuota{},
+			&pannoiv1beta1.Policy{}, &pannoiv1beta1.PolicyBinding{}, &pannoiv1beta1.PolicyCheck{},
+			&pannoiv1beta1.ServiceAccountIdentity{}, &pannoiv1beta1.TemporaryCredentials{}, &pannoiv1beta1.User{},
+		).
+		WithIndex(&pannoiv1beta1.Bucket{}, specNameField, func(obj client.Object) []string {
+			return bucketNames(obj.(*pannoiv1beta1.Bucket))
+		}).
+		WithIndex(&pannoiv1beta1.User{}, specNameField, func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.User).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.Policy{}, specNameField, func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.Policy).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.Group{}, specNameField, func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.Group).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.User{}, userGroupsField, func(obj client.Object) []string {
+			return obj.(*pannoiv1beta1.User).Spec.Groups
+		}).
+		WithIndex(&pannoiv1beta1.Policy{}, statementConfigMapField, func(obj client.Object) []string {
+			policy := obj.(*pannoiv1beta1.Policy)
+			if policy.Spec.StatementFrom == nil || policy.Spec.StatementFrom.ConfigMapKeyRef == nil {
+				return nil
+			}
+			return []string{policy.Spec.StatementFrom.ConfigMapKeyRef.Name}
+		}).
+		WithIndex(&pannoiv1beta1.Policy{}, statementSecretField, func(obj client.Object) []string {
+			policy := obj.(*pannoiv1beta1.Policy)
+			if policy.Spec.StatementFrom == nil || policy.Spec.StatementFrom.SecretKeyRef == nil {
+				return nil
+			}
+			return []string{policy.Spec.StatementFrom.SecretKeyRef.Name}
+		}).
+		WithIndex(&pannoiv1beta1.User{}, passwordSecretRefField, func(obj client.Object) []string {
+			user := obj.(*pannoiv1beta1.User)
+			if user.Spec.PasswordSecretRef == nil {
+				return nil
+			}
+			return []string{user.Spec.PasswordSecretRef.Name}
+		}).
+		Build()
+}
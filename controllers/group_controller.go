@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+const userGroupsField = ".spec.groups"
+
+type GroupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	group := &pannoiv1beta1.Group{}
+	err := r.Get(ctx, req.NamespacedName, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("Group resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get Group resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	claimant, err := conflictingClaim(ctx, r.Client, &pannoiv1beta1.GroupList{}, group, group.Spec.Name)
+	if err != nil {
+		log.Error(err, "Failed to check claims of group name: "+group.Spec.Name)
+		return ctrl.Result{}, err
+	}
+
+	if !group.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(group, minioFinalizer) {
+			// Only the owner of the group name manages the minio group.
+			if claimant == "" {
+				err = mc.SetPolicy(ctx, "", group.Spec.Name, true)
+				if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminNoSuchGroup" {
+					log.Error(err, "Failed to detach policies from group: "+group.Spec.Name)
+					return ctrl.Result{}, err
+				}
+				err = deleteGroup(ctx, mc, group.Spec.Name)
+				if err != nil {
+					log.Error(err, "Failed to delete group: "+group.Spec.Name)
+					return ctrl.Result{}, err
+				}
+			}
+			controllerutil.RemoveFinalizer(group, minioFinalizer)
+			err = r.Update(ctx, group)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("Group was deleted: " + group.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(group, minioFinalizer) {
+		controllerutil.AddFinalizer(group, minioFinalizer)
+		err = r.Update(ctx, group)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if claimant != "" {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NameConflict",
+			Message: "Group name " + group.Spec.Name + " is claimed by " + claimant,
+		}
+		group.Status.NameConflict = claimant
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Group name " + group.Spec.Name + " is claimed by " + claimant)
+		return ctrl.Result{}, nil
+	}
+	group.Status.NameConflict = ""
+
+	err = CheckNamespaceName(ctx, r.Client, group.Namespace, "group", group.Spec.Name)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NamespacePrefix",
+			Message: err.Error(),
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Group name is not prefixed with namespace prefix: " + group.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	err = CheckGroupMembers(ctx, r.Client, group.Namespace, group.Spec.Members)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Member denied",
+			Message: err.Error(),
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Members of group are denied: " + group.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	users := &pannoiv1beta1.UserList{}
+	err = r.List(ctx, users, client.InNamespace(group.Namespace), client.MatchingFields{userGroupsField: group.Spec.Name})
+	if err != nil {
+		log.Error(err, "Failed to list users of group: "+group.Spec.Name)
+		return ctrl.Result{}, err
+	}
+
+	members := append([]string{}, group.Spec.Members...)
+	for _, user := range users.Items {
+		if !containsString(members, user.Spec.Name) {
+			members = append(members, user.Spec.Name)
+		}
+	}
+
+	err = mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+		Group:   group.Spec.Name,
+		Members: members,
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to update group members",
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update members of group: "+group.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	desc, err := mc.GetGroupDescription(ctx, group.Spec.Name)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get group info",
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get group info: "+group.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	var removed []string
+	for _, member := range desc.Members {
+		if !containsString(members, member) {
+			removed = append(removed, member)
+		}
+	}
+	if len(removed) > 0 {
+		err = mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+			Group:    group.Spec.Name,
+			Members:  removed,
+			IsRemove: true,
+		})
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to update group members",
+			}
+			setCondition(&group.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, group)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to remove members from group: "+group.Spec.Name)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	err = CheckPolicyReferences(ctx, r.Client, group.Namespace, group.Spec.Policies)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Policy reference denied",
+			Message: err.Error(),
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policies of group are denied: " + group.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	err = applySubjectPolicies(ctx, r.Client, mc, pannoiv1beta1.Subject{Kind: "Group", Name: group.Spec.Name})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to attach policy",
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to attach policies to group: "+group.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	groupStatus := madmin.GroupEnabled
+	if group.Spec.Enabled != nil && !*group.Spec.Enabled {
+		groupStatus = madmin.GroupDisabled
+	}
+
+	err = mc.SetGroupStatus(ctx, group.Spec.Name, groupStatus)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to set group status",
+		}
+		setCondition(&group.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, group)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to set status "+string(groupStatus)+" for group: "+group.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	group.Status.Members = members
+	group.Status.GroupStatus = string(groupStatus)
+	setCondition(&group.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, group)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Group was reconciled: " + group.Spec.Name)
+	return ctrl.Result{}, nil
+}
+
+// CheckGroupMembers rejects members which are not minio users managed by a User or BucketAccess of
+// the namespace, so a namespace cannot add users of other namespaces to its groups.
+func CheckGroupMembers(ctx context.Context, c client.Client, namespace string, members []string) error {
+	for _, member := range members {
+		owned, err := subjectOwned(ctx, c, namespace, pannoiv1beta1.Subject{Kind: "User", Name: member})
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return fmt.Errorf("member %q is not a user of namespace %s", member, namespace)
+		}
+	}
+	return nil
+}
+
+// managedGroups returns the groups of the list owned by a Group resource of the namespace which
+// is not being deleted. Joining other groups would create them in minio behind the operator, or
+// grant the policies of groups owned by other namespaces.
+func managedGroups(ctx context.Context, c client.Client, namespace string, names []string) ([]string, error) {
+	managed := []string{}
+	for _, name := range names {
+		claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.GroupList{}, name)
+		if err != nil {
+			return nil, err
+		}
+		if claimant != nil && claimant.GetNamespace() == namespace && claimant.GetDeletionTimestamp().IsZero() && !containsString(managed, name) {
+			managed = append(managed, name)
+		}
+	}
+	return managed, nil
+}
+
+// deleteGroup removes all members of the group, after which minio drops the empty group.
+func deleteGroup(ctx context.Context, mc *madmin.AdminClient, name string) error {
+	desc, err := mc.GetGroupDescription(ctx, name)
+	if err != nil {
+		if madmin.ToErrorResponse(err).Code == "XMinioAdminNoSuchGroup" {
+			return nil
+		}
+		return err
+	}
+
+	if len(desc.Members) > 0 {
+		err = mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+			Group:    name,
+			Members:  desc.Members,
+			IsRemove: true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+		Group:    name,
+		Members:  []string{},
+		IsRemove: true,
+	})
+}
+
+// findGroupsForUser maps a User to the Groups it joins or has left.
+func (r *GroupReconciler) findGroupsForUser(ctx context.Context, obj client.Object) []reconcile.Request {
+	user := obj.(*pannoiv1beta1.User)
+
+	groups := &pannoiv1beta1.GroupList{}
+	err := r.List(ctx, groups)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range groups.Items {
+		if item.Namespace != user.Namespace {
+			continue
+		}
+		if containsString(user.Spec.Groups, item.Spec.Name) || containsString(user.Status.Groups, item.Spec.Name) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// findGroupsWithSameName maps a Group to the other Groups claiming the same minio name.
+func (r *GroupReconciler) findGroupsWithSameName(ctx context.Context, obj client.Object) []reconcile.Request {
+	group := obj.(*pannoiv1beta1.Group)
+	return sameNameRequests(ctx, r.Client, &pannoiv1beta1.GroupList{}, group, group.Spec.Name)
+}
+
+func (r *GroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.User{}, userGroupsField, func(obj client.Object) []string {
+		return obj.(*pannoiv1beta1.User).Spec.Groups
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.Group{}, specNameField, func(obj client.Object) []string {
+		return []string{obj.(*pannoiv1beta1.Group).Spec.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.Group{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Group{}, handler.EnqueueRequestsFromMapFunc(r.findGroupsWithSameName)).
+		Watches(&pannoiv1beta1.User{}, handler.EnqueueRequestsFromMapFunc(r.findGroupsForUser)).
+		Complete(r)
+}
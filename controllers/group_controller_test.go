@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestManagedGroups(t *testing.T) {
+	now := metav1.Now()
+	c := newFakeClient(
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "developers"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "leaving", Namespace: "team-a", DeletionTimestamp: &now, Finalizers: []string{minioFinalizer}},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "leaving"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "admins"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-b", CreationTimestamp: metav1.NewTime(time.Now().Add(time.Hour))},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "developers"},
+		},
+	)
+
+	groups, err := managedGroups(context.Background(), c, "team-a", []string{"developers", "leaving", "admins", "unmanaged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(groups, []string{"developers"}) {
+		t.Errorf("expected only the live group of the namespace, got %v", groups)
+	}
+
+	groups, err = managedGroups(context.Background(), c, "team-b", []string{"developers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("expected a group claimed by another namespace not to be managed, got %v", groups)
+	}
+}
+
+func TestCheckGroupMembers(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+		},
+		&pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketAccessSpec{Bucket: "data", Access: "read", User: "app-user"},
+		},
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "bob"},
+		},
+	)
+
+	tests := []struct {
+		members []string
+		allowed bool
+	}{
+		{nil, true},
+		{[]string{"alice", "app-user"}, true},
+		{[]string{"alice", "bob"}, false},
+		{[]string{"root"}, false},
+	}
+	for _, tt := range tests {
+		err := CheckGroupMembers(context.Background(), c, "team-a", tt.members)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%v: expected allowed %v, got %v", tt.members, tt.allowed, err)
+		}
+	}
+}
+
+func TestGroupReconcileNameConflict(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "127.0.0.1:1")
+	c := newFakeClient(
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "admins"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "team-b", CreationTimestamp: metav1.NewTime(time.Now().Add(time.Hour))},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "admins", Members: []string{"mallory"}},
+		},
+	)
+	r := &GroupReconciler{Client: c}
+	key := types.NamespacedName{Name: "admins", Namespace: "team-b"}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("expected a conflicting group not to reach minio, got %v", err)
+	}
+	group := &pannoiv1beta1.Group{}
+	err = c.Get(context.Background(), key, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Status.NameConflict != "team-a/admins" || len(group.Status.Conditions) != 1 || group.Status.Conditions[0].Reason != "NameConflict" {
+		t.Errorf("expected a NameConflict with team-a/admins, got %+v", group.Status)
+	}
+}
+
+func TestSubjectPoliciesSkipsDeletedGroups(t *testing.T) {
+	now := metav1.Now()
+	c := newFakeClient(
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-a", DeletionTimestamp: &now, Finalizers: []string{minioFinalizer}},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "developers", Policies: []string{"readonly"}},
+		},
+	)
+
+	policies, err := subjectPolicies(context.Background(), c, pannoiv1beta1.Subject{Kind: "Group", Name: "developers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 0 {
+		t.Errorf("expected no policies for a deleted group, got %v", policies)
+	}
+}
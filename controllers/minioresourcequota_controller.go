@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+type MinioResourceQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// namespaceUsage counts what the resources declared in the namespace create in minio: Buckets and their
+// quotas, Users and BucketAccess users, Groups, and Policies with the inline, home, BucketAccess and
+// ServiceAccountIdentity policies. Home policies are shared by the users of a bucket and counted once
+// per bucket, ServiceAccountIdentities once per service account.
+//
+// The declared object, if set, is counted in place of its stored version, so the result is the usage
+// once it is admitted. Objects being deleted are not counted.
+func namespaceUsage(ctx context.Context, c client.Client, namespace string, declared client.Object) (pannoiv1beta1.MinioResourceUsage, error) {
+	usage := pannoiv1beta1.MinioResourceUsage{}
+
+	buckets := &pannoiv1beta1.BucketList{}
+	err := c.List(ctx, buckets, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	countBucket := func(bucket *pannoiv1beta1.Bucket) {
+		if !bucket.DeletionTimestamp.IsZero() {
+			return
+		}
+		usage.Buckets++
+		if bucket.Spec.Quota != nil {
+			usage.BucketQuota.Add(*bucket.Spec.Quota)
+		} else {
+			usage.UnlimitedBuckets++
+		}
+	}
+	for i := range buckets.Items {
+		if d, ok := declared.(*pannoiv1beta1.Bucket); !ok || d.Name != buckets.Items[i].Name {
+			countBucket(&buckets.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.Bucket); ok {
+		countBucket(d)
+	}
+
+	users := &pannoiv1beta1.UserList{}
+	err = c.List(ctx, users, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	homes := map[string]bool{}
+	countUser := func(user *pannoiv1beta1.User) {
+		if !user.DeletionTimestamp.IsZero() {
+			return
+		}
+		usage.Users++
+		if user.Spec.InlinePolicy != "" {
+			usage.Policies++
+		}
+		if user.Spec.Home != nil && !homes[user.Spec.Home.Bucket] {
+			homes[user.Spec.Home.Bucket] = true
+			usage.Policies++
+		}
+	}
+	for i := range users.Items {
+		if d, ok := declared.(*pannoiv1beta1.User); !ok || d.Name != users.Items[i].Name {
+			countUser(&users.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.User); ok {
+		countUser(d)
+	}
+
+	accesses := &pannoiv1beta1.BucketAccessList{}
+	err = c.List(ctx, accesses, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	countAccess := func(access *pannoiv1beta1.BucketAccess) {
+		if access.DeletionTimestamp.IsZero() {
+			usage.Users++
+			usage.Policies++
+		}
+	}
+	for i := range accesses.Items {
+		if d, ok := declared.(*pannoiv1beta1.BucketAccess); !ok || d.Name != accesses.Items[i].Name {
+			countAccess(&accesses.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.BucketAccess); ok {
+		countAccess(d)
+	}
+
+	groups := &pannoiv1beta1.GroupList{}
+	err = c.List(ctx, groups, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	countGroup := func(group *pannoiv1beta1.Group) {
+		if group.DeletionTimestamp.IsZero() {
+			usage.Groups++
+		}
+	}
+	for i := range groups.Items {
+		if d, ok := declared.(*pannoiv1beta1.Group); !ok || d.Name != groups.Items[i].Name {
+			countGroup(&groups.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.Group); ok {
+		countGroup(d)
+	}
+
+	policies := &pannoiv1beta1.PolicyList{}
+	err = c.List(ctx, policies, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	countPolicy := func(policy *pannoiv1beta1.Policy) {
+		if policy.DeletionTimestamp.IsZero() {
+			usage.Policies++
+		}
+	}
+	for i := range policies.Items {
+		if d, ok := declared.(*pannoiv1beta1.Policy); !ok || d.Name != policies.Items[i].Name {
+			countPolicy(&policies.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.Policy); ok {
+		countPolicy(d)
+	}
+
+	identities := &pannoiv1beta1.ServiceAccountIdentityList{}
+	err = c.List(ctx, identities, client.InNamespace(namespace))
+	if err != nil {
+		return usage, err
+	}
+	serviceAccounts := map[string]bool{}
+	countIdentity := func(identity *pannoiv1beta1.ServiceAccountIdentity) {
+		if identity.DeletionTimestamp.IsZero() && !serviceAccounts[identity.Spec.ServiceAccountName] {
+			serviceAccounts[identity.Spec.ServiceAccountName] = true
+			usage.Policies++
+		}
+	}
+	for i := range identities.Items {
+		if d, ok := declared.(*pannoiv1beta1.ServiceAccountIdentity); !ok || d.Name != identities.Items[i].Name {
+			countIdentity(&identities.Items[i])
+		}
+	}
+	if d, ok := declared.(*pannoiv1beta1.ServiceAccountIdentity); ok {
+		countIdentity(d)
+	}
+
+	return usage, nil
+}
+
+// CheckResourceQuotas rejects the object if declaring it exceeds a MinioResourceQuota of its namespace.
+// Limits are only checked when the object raises their usage, so unrelated updates of resources declared
+// before the quota are admitted. While a quota sets bucketQuota, Buckets have to set spec.quota: it is
+// required on creation and may not be removed, old is the stored object on updates and nil on creation.
+func CheckResourceQuotas(ctx context.Context, c client.Client, obj, old client.Object) error {
+	quotas := &pannoiv1beta1.MinioResourceQuotaList{}
+	err := c.List(ctx, quotas, client.InNamespace(obj.GetNamespace()))
+	if err != nil || len(quotas.Items) == 0 {
+		return err
+	}
+
+	current, err := namespaceUsage(ctx, c, obj.GetNamespace(), nil)
+	if err != nil {
+		return err
+	}
+	requested, err := namespaceUsage(ctx, c, obj.GetNamespace(), obj)
+	if err != nil {
+		return err
+	}
+
+	for _, quota := range quotas.Items {
+		hard := quota.Spec
+		if bucket, ok := obj.(*pannoiv1beta1.Bucket); ok && hard.BucketQuota != nil && bucket.Spec.Quota == nil {
+			oldBucket, ok := old.(*pannoiv1beta1.Bucket)
+			if !ok || oldBucket.Spec.Quota != nil {
+				return fmt.Errorf("quota %s limits bucketQuota, spec.quota is required", quota.Name)
+			}
+		}
+		limits := []struct {
+			name               string
+			hard               *int32
+			current, requested int32
+		}{
+			{"buckets", hard.Buckets, current.Buckets, requested.Buckets},
+			{"users", hard.Users, current.Users, requested.Users},
+			{"groups", hard.Groups, current.Groups, requested.Groups},
+			{"policies", hard.Policies, current.Policies, requested.Policies},
+		}
+		for _, limit := range limits {
+			if limit.hard != nil && limit.requested > *limit.hard && limit.requested > limit.current {
+				return fmt.Errorf("exceeded quota %s: %s %d/%d", quota.Name, limit.name, limit.requested, *limit.hard)
+			}
+		}
+		if hard.BucketQuota != nil && requested.BucketQuota.Cmp(*hard.BucketQuota) > 0 && requested.BucketQuota.Cmp(current.BucketQuota) > 0 {
+			return fmt.Errorf("exceeded quota %s: bucketQuota %s/%s", quota.Name, requested.BucketQuota.String(), hard.BucketQuota.String())
+		}
+	}
+	return nil
+}
+
+// quotaExceeded returns the limits of the quota exceeded by the usage, e.g. declared before the quota.
+// Buckets without quota exceed any bucketQuota.
+func quotaExceeded(hard pannoiv1beta1.MinioResourceQuotaSpec, used pannoiv1beta1.MinioResourceUsage) []string {
+	var exceeded []string
+	if hard.Buckets != nil && used.Buckets > *hard.Buckets {
+		exceeded = append(exceeded, "buckets")
+	}
+	if hard.Users != nil && used.Users > *hard.Users {
+		exceeded = append(exceeded, "users")
+	}
+	if hard.Groups != nil && used.Groups > *hard.Groups {
+		exceeded = append(exceeded, "groups")
+	}
+	if hard.Policies != nil && used.Policies > *hard.Policies {
+		exceeded = append(exceeded, "policies")
+	}
+	if hard.BucketQuota != nil && (used.BucketQuota.Cmp(*hard.BucketQuota) > 0 || used.UnlimitedBuckets > 0) {
+		exceeded = append(exceeded, "bucketQuota")
+	}
+	return exceeded
+}
+
+func (r *MinioResourceQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	quota := &pannoiv1beta1.MinioResourceQuota{}
+	err := r.Get(ctx, req.NamespacedName, quota)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("MinioResourceQuota resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get MinioResourceQuota resource")
+		return ctrl.Result{}, err
+	}
+
+	usage, err := namespaceUsage(ctx, r.Client, req.Namespace, nil)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to calculate usage",
+		}
+		setCondition(&quota.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, quota)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to calculate usage of namespace: "+req.Namespace)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	if exceeded := quotaExceeded(quota.Spec, usage); len(exceeded) > 0 {
+		conditions = metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Exceeded",
+			Message: fmt.Sprintf("Usage exceeds %v", exceeded),
+		}
+	}
+	quota.Status.Used = usage
+	setCondition(&quota.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, quota)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("MinioResourceQuota was reconciled: " + quota.Name)
+	return ctrl.Result{}, nil
+}
+
+// findQuotasForObject maps a resource counted by namespaceUsage to the MinioResourceQuotas of its namespace.
+func (r *MinioResourceQuotaReconciler) findQuotasForObject(ctx context.Context, obj client.Object) []reconcile.Request {
+	quotas := &pannoiv1beta1.MinioResourceQuotaList{}
+	err := r.List(ctx, quotas, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(quotas.Items))
+	for i, item := range quotas.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+			},
+		}
+	}
+	return requests
+}
+
+func (r *MinioResourceQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.MinioResourceQuota{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Bucket{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Watches(&pannoiv1beta1.User{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Watches(&pannoiv1beta1.BucketAccess{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Watches(&pannoiv1beta1.Group{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Watches(&pannoiv1beta1.ServiceAccountIdentity{}, handler.EnqueueRequestsFromMapFunc(r.findQuotasForObject)).
+		Complete(r)
+}
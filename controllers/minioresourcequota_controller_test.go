@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func quantityPtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestNamespaceUsage(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-data", Quota: quantityPtr("10Gi")},
+		},
+		&pannoiv1beta1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-logs"},
+		},
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec: pannoiv1beta1.UserSpec{
+				Name:         "alice",
+				InlinePolicy: `{"Version":"2012-10-17","Statement":[]}`,
+				Home:         &pannoiv1beta1.HomeSpec{Bucket: "team-a-data"},
+			},
+		},
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "bob", Home: &pannoiv1beta1.HomeSpec{Bucket: "team-a-data"}},
+		},
+		&pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketAccessSpec{Bucket: "team-a-data", Access: "read"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "devs"},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-a-read"},
+		},
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+		},
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-copy", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+		},
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "carol", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "carol"},
+		},
+	)
+
+	usage, err := namespaceUsage(context.Background(), c, "team-a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := pannoiv1beta1.MinioResourceUsage{
+		Buckets: 2,
+		// alice, bob and the BucketAccess user
+		Users:  3,
+		Groups: 1,
+		// read, alice inline, shared home of team-a-data, BucketAccess and worker identity
+		Policies:         5,
+		BucketQuota:      resource.MustParse("10Gi"),
+		UnlimitedBuckets: 1,
+	}
+	if usage.Buckets != expected.Buckets || usage.Users != expected.Users || usage.Groups != expected.Groups ||
+		usage.Policies != expected.Policies || usage.UnlimitedBuckets != expected.UnlimitedBuckets ||
+		usage.BucketQuota.Cmp(expected.BucketQuota) != 0 {
+		t.Errorf("expected %+v, got %+v", expected, usage)
+	}
+
+	// The declared object replaces its stored version.
+	declared := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-logs", Quota: quantityPtr("5Gi")},
+	}
+	usage, err = namespaceUsage(context.Background(), c, "team-a", declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Buckets != 2 || usage.UnlimitedBuckets != 0 || usage.BucketQuota.Cmp(resource.MustParse("15Gi")) != 0 {
+		t.Errorf("expected declared bucket in place of the stored one, got %+v", usage)
+	}
+}
+
+func TestCheckResourceQuotas(t *testing.T) {
+	stored := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-data", Quota: quantityPtr("10Gi")},
+	}
+	legacy := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-legacy"},
+	}
+	c := newFakeClient(
+		stored,
+		legacy,
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+		},
+		&pannoiv1beta1.MinioResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "team-a"},
+			Spec: pannoiv1beta1.MinioResourceQuotaSpec{
+				Users:       int32Ptr(2),
+				Groups:      int32Ptr(0),
+				Policies:    int32Ptr(1),
+				BucketQuota: quantityPtr("20Gi"),
+			},
+		},
+	)
+
+	bucket := func(name, quota string) *pannoiv1beta1.Bucket {
+		b := &pannoiv1beta1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-" + name},
+		}
+		if quota != "" {
+			b.Spec.Quota = quantityPtr(quota)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		obj     client.Object
+		old     client.Object
+		allowed bool
+	}{
+		{"bucket within bucketQuota", bucket("logs", "10Gi"), nil, true},
+		{"bucket exceeding bucketQuota", bucket("logs", "11Gi"), nil, false},
+		{"bucket without quota", bucket("logs", ""), nil, false},
+		{"quota removed", bucket("data", ""), stored, false},
+		{"quota raised", bucket("data", "20Gi"), stored, true},
+		{"bucket declared before the quota updated", bucket("legacy", ""), legacy, true},
+	}
+	for _, tt := range tests {
+		err := CheckResourceQuotas(context.Background(), c, tt.obj, tt.old)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+
+	access := &pannoiv1beta1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}}
+	if err := CheckResourceQuotas(context.Background(), c, access, nil); err != nil {
+		t.Errorf("expected BucketAccess user and policy within quota, got %v", err)
+	}
+	group := &pannoiv1beta1.Group{ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "team-a"}}
+	if err := CheckResourceQuotas(context.Background(), c, group, nil); err == nil {
+		t.Error("expected group exceeding quota to be rejected")
+	}
+	user := &pannoiv1beta1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.UserSpec{Name: "alice", InlinePolicy: `{}`, Home: &pannoiv1beta1.HomeSpec{Bucket: "team-a-data"}},
+	}
+	if err := CheckResourceQuotas(context.Background(), c, user, &pannoiv1beta1.User{}); err == nil {
+		t.Error("expected inline and home policies exceeding quota to be rejected")
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	hard := pannoiv1beta1.MinioResourceQuotaSpec{Groups: int32Ptr(1), BucketQuota: quantityPtr("10Gi")}
+
+	exceeded := quotaExceeded(hard, pannoiv1beta1.MinioResourceUsage{Groups: 2, BucketQuota: resource.MustParse("1Gi"), UnlimitedBuckets: 1})
+	if !reflect.DeepEqual(exceeded, []string{"groups", "bucketQuota"}) {
+		t.Errorf("expected groups and bucketQuota exceeded, got %v", exceeded)
+	}
+	exceeded = quotaExceeded(hard, pannoiv1beta1.MinioResourceUsage{Groups: 1, BucketQuota: resource.MustParse("10Gi")})
+	if len(exceeded) != 0 {
+		t.Errorf("expected nothing exceeded, got %v", exceeded)
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespacePrefixAnnotation on a Namespace overrides the prefix of minio names declared in it.
+const NamespacePrefixAnnotation = "minio-resource-operator.pannoi/name-prefix"
+
+// NamespaceNaming returns the naming mode set by env:NAMESPACE_NAMING: "prefix" prefixes minio names
+// of Buckets, Users, Policies and Groups with the namespace prefix, "require" only requires it, empty disables it.
+func NamespaceNaming() string {
+	return strings.TrimSpace(os.Getenv("NAMESPACE_NAMING"))
+}
+
+// namespacePrefixSeparator ends every namespace prefix.
+const namespacePrefixSeparator = "."
+
+// NamespacePrefix returns the prefix of minio names in the namespace: the namespace annotation value,
+// or the namespace name, followed by the separator. Neither may contain the separator, so the separator
+// always ends the prefix, and an annotation value is rejected if its prefix overlaps the one of another
+// namespace, so no namespace can claim names of another one.
+func NamespacePrefix(ctx context.Context, c client.Client, namespace string) (string, error) {
+	ns := &corev1.Namespace{}
+	err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if err != nil {
+		return "", err
+	}
+	value := ns.Annotations[NamespacePrefixAnnotation]
+	if value == "" {
+		return namespace + namespacePrefixSeparator, nil
+	}
+	if strings.ContainsAny(value, "*?") {
+		return "", fmt.Errorf("annotation %s of namespace %s must not contain wildcards", NamespacePrefixAnnotation, namespace)
+	}
+	if strings.Contains(value, namespacePrefixSeparator) {
+		return "", fmt.Errorf("annotation %s of namespace %s must not contain the separator %q", NamespacePrefixAnnotation, namespace, namespacePrefixSeparator)
+	}
+	prefix := value + namespacePrefixSeparator
+
+	namespaces := &corev1.NamespaceList{}
+	err = c.List(ctx, namespaces)
+	if err != nil {
+		return "", err
+	}
+	for _, other := range namespaces.Items {
+		if other.Name == namespace {
+			continue
+		}
+		otherPrefix := other.Name + namespacePrefixSeparator
+		if otherValue := other.Annotations[NamespacePrefixAnnotation]; otherValue != "" {
+			otherPrefix = otherValue + namespacePrefixSeparator
+		}
+		if strings.HasPrefix(otherPrefix, prefix) || strings.HasPrefix(prefix, otherPrefix) {
+			return "", fmt.Errorf("annotation %s of namespace %s overlaps prefix %q of namespace %s", NamespacePrefixAnnotation, namespace, otherPrefix, other.Name)
+		}
+	}
+	return prefix, nil
+}
+
+// CheckNamespaceName rejects minio names without the namespace prefix when namespace naming is enabled.
+func CheckNamespaceName(ctx context.Context, c client.Client, namespace, kind, name string) error {
+	if NamespaceNaming() == "" {
+		return nil
+	}
+	prefix, err := NamespacePrefix(ctx, c, namespace)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(name, prefix) {
+		return fmt.Errorf("%s name %q must be prefixed with %q", kind, name, prefix)
+	}
+	return nil
+}
+
+// CheckNamespaceResources rejects Allow statements of the policy document granting buckets
+// without the namespace prefix or admin actions when namespace naming is enabled. Statements have to
+// name their resources, NotResource and NotAction are rejected as they grant everything else.
+func CheckNamespaceResources(ctx context.Context, c client.Client, namespace, document string) error {
+	if NamespaceNaming() == "" {
+		return nil
+	}
+	prefix, err := NamespacePrefix(ctx, c, namespace)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParsePolicyDocument(document)
+	if err != nil {
+		return err
+	}
+
+	for i, statement := range parsed.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		if len(statement.NotResource) > 0 {
+			return fmt.Errorf("Statement[%d]: NotResource is forbidden, it grants buckets without prefix %s", i, prefix)
+		}
+		if len(statement.Resource) == 0 {
+			return fmt.Errorf("Statement[%d]: Resource is required, limited to buckets with prefix %s", i, prefix)
+		}
+		if len(statement.NotAction) > 0 {
+			return fmt.Errorf("Statement[%d]: NotAction is forbidden, it grants admin actions", i)
+		}
+		for _, action := range statement.Action {
+			if strings.HasPrefix(action, "admin:") || matchesAny(action, guardrailAdminProbes) {
+				return fmt.Errorf("Statement[%d]: admin action %q is forbidden", i, action)
+			}
+		}
+		for _, resource := range statement.Resource {
+			bucket := strings.SplitN(strings.TrimPrefix(resource, "arn:aws:s3:::"), "/", 2)[0]
+			if !strings.HasPrefix(resource, "arn:aws:s3:::") || !strings.HasPrefix(bucket, prefix) {
+				return fmt.Errorf("Statement[%d]: resource %q is not a bucket with prefix %s", i, resource, prefix)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func namespaceWithPrefix(name, prefix string) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if prefix != "" {
+		ns.Annotations = map[string]string{NamespacePrefixAnnotation: prefix}
+	}
+	return ns
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	c := newFakeClient(
+		namespaceWithPrefix("team", ""),
+		namespaceWithPrefix("team-a", ""),
+		namespaceWithPrefix("analytics", "data"),
+		namespaceWithPrefix("copycat", "team"),
+		namespaceWithPrefix("nested", "team-a.sub"),
+		namespaceWithPrefix("wildcard", "t*"),
+	)
+
+	tests := []struct {
+		namespace string
+		prefix    string
+	}{
+		{"team", "team."},
+		{"team-a", "team-a."},
+		{"analytics", "data."},
+		{"copycat", ""},
+		{"nested", ""},
+		{"wildcard", ""},
+	}
+	for _, tt := range tests {
+		prefix, err := NamespacePrefix(context.Background(), c, tt.namespace)
+		if tt.prefix == "" {
+			if err == nil {
+				t.Errorf("%s: expected prefix to be rejected, got %q", tt.namespace, prefix)
+			}
+			continue
+		}
+		if err != nil || prefix != tt.prefix {
+			t.Errorf("%s: expected %q, got %q (%v)", tt.namespace, tt.prefix, prefix, err)
+		}
+	}
+}
+
+func TestCheckNamespaceResources(t *testing.T) {
+	t.Setenv("NAMESPACE_NAMING", "require")
+	c := newFakeClient(namespaceWithPrefix("team-a", ""))
+
+	tests := []struct {
+		name      string
+		statement string
+		allowed   bool
+	}{
+		{"own buckets", `{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::team-a.*"]}`, true},
+		{"other buckets", `{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::*"]}`, false},
+		{"no resource", `{"Effect":"Allow","Action":["s3:ListAllMyBuckets"]}`, false},
+		{"not resource", `{"Effect":"Allow","Action":["s3:GetObject"],"NotResource":["arn:aws:s3:::team-b.*"]}`, false},
+		{"not action", `{"Effect":"Allow","NotAction":["s3:GetObject"],"Resource":["arn:aws:s3:::team-a.*"]}`, false},
+		{"admin actions", `{"Effect":"Allow","Action":["admin:*"],"Resource":["arn:aws:s3:::team-a.*"]}`, false},
+		{"admin pattern", `{"Effect":"Allow","Action":["*"],"Resource":["arn:aws:s3:::team-a.*"]}`, false},
+		{"deny everything", `{"Effect":"Deny","Action":["*"],"Resource":["*"]}`, true},
+	}
+	for _, tt := range tests {
+		err := CheckNamespaceResources(context.Background(), c, "team-a", `{"Version":"2012-10-17","Statement":[`+tt.statement+`]}`)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+}
+
+func TestSubjectPoliciesNamespaceOwned(t *testing.T) {
+	t.Setenv("NAMESPACE_NAMING", "require")
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "team-a.alice", Policies: []string{"team-a.read", "team-b.admin", "readwrite"}},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-a.read"},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-b.admin"},
+		},
+	)
+
+	policies, err := subjectPolicies(context.Background(), c, pannoiv1beta1.Subject{Kind: "User", Name: "team-a.alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(policies, []string{"team-a.read"}) {
+		t.Errorf("expected only the policy of the namespace, got %v", policies)
+	}
+
+	err = CheckPolicyReferences(context.Background(), c, "team-a", []string{"team-b.admin"})
+	if err == nil {
+		t.Error("expected policy of another namespace to be rejected")
+	}
+}
@@ -1,25 +1,546 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/minio/madmin-go"
+	"github.com/minio/pkg/wildcard"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
 
 	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
 )
 
+// policyEntitiesRefreshPeriod is how often the users and groups attached to a policy are refreshed in status.
+const policyEntitiesRefreshPeriod = 5 * time.Minute
+
+const (
+	statementConfigMapField = ".spec.statementFrom.configMapKeyRef.name"
+	statementSecretField    = ".spec.statementFrom.secretKeyRef.name"
+)
+
 type PolicyReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	entities policyEntitySnapshot
+}
+
+// policyEntitySnapshot holds the users and groups of all policies, listed once per refresh period and
+// shared by the reconciles of all Policies, as minio has no API listing the entities of a single policy.
+type policyEntitySnapshot struct {
+	mu        sync.Mutex
+	fetchedAt time.Time
+	users     map[string][]string
+	groups    map[string][]string
+}
+
+// get returns the users and groups of the policy, listing them with fetch if the snapshot is older
+// than policyEntitiesRefreshPeriod.
+func (s *policyEntitySnapshot) get(name string, now time.Time, fetch func() (map[string][]string, map[string][]string, error)) ([]string, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users == nil || now.Sub(s.fetchedAt) >= policyEntitiesRefreshPeriod {
+		users, groups, err := fetch()
+		if err != nil {
+			return nil, nil, err
+		}
+		s.users, s.groups, s.fetchedAt = users, groups, now
+	}
+	return append([]string{}, s.users[name]...), append([]string{}, s.groups[name]...), nil
+}
+
+// presetActions are the object actions granted by each policy preset, list-only grants none.
+var presetActions = map[string][]string{
+	"readonly":          {"s3:GetObject"},
+	"writeonly":         {"s3:PutObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+	"readwrite":         {"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+	"list-only":         {},
+	"consoleAdmin-lite": {"s3:*"},
+}
+
+// presetDocument generates the policy document of a preset, Bucket references are resolved
+// in the policy namespace.
+func presetDocument(ctx context.Context, c client.Client, namespace string, preset *pannoiv1beta1.PolicyPreset) (*pannoiv1beta1.PolicyDocument, error) {
+	objectActions, ok := presetActions[preset.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", preset.Type)
+	}
+
+	document := &pannoiv1beta1.PolicyDocument{Version: policyVersion}
+	if preset.Type == "consoleAdmin-lite" {
+		document.Statement = append(document.Statement, pannoiv1beta1.PolicyStatement{
+			Effect:   "Allow",
+			Action:   []string{"s3:ListAllMyBuckets"},
+			Resource: []string{"arn:aws:s3:::*"},
+		})
+	}
+
+	for _, target := range preset.Buckets {
+		bucketName := target.Name
+		if target.BucketRef != "" {
+			bucket := &pannoiv1beta1.Bucket{}
+			err := c.Get(ctx, types.NamespacedName{Name: target.BucketRef, Namespace: namespace}, bucket)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get bucket %s: %w", target.BucketRef, err)
+			}
+			bucketName = BucketName(bucket)
+		}
+		if bucketName == "" {
+			return nil, fmt.Errorf("one of name or bucketRef must be set on preset buckets")
+		}
+
+		bucketArn := "arn:aws:s3:::" + bucketName
+		objectArn := bucketArn + "/*"
+		var listCondition map[string]map[string][]string
+		prefix := strings.Trim(target.Prefix, "/")
+		if prefix != "" {
+			objectArn = bucketArn + "/" + prefix + "/*"
+			listCondition = map[string]map[string][]string{
+				"StringLike": {"s3:prefix": {"", prefix + "/", prefix + "/*"}},
+			}
+		}
+
+		if preset.Type == "consoleAdmin-lite" && prefix == "" {
+			document.Statement = append(document.Statement, pannoiv1beta1.PolicyStatement{
+				Effect:   "Allow",
+				Action:   []string{"s3:*"},
+				Resource: []string{bucketArn, objectArn},
+			})
+			continue
+		}
+
+		bucketActions := []string{"s3:GetBucketLocation"}
+		if preset.Type != "writeonly" {
+			document.Statement = append(document.Statement, pannoiv1beta1.PolicyStatement{
+				Effect:    "Allow",
+				Action:    []string{"s3:ListBucket"},
+				Resource:  []string{bucketArn},
+				Condition: listCondition,
+			})
+		}
+		if preset.Type != "readonly" && preset.Type != "list-only" {
+			bucketActions = append(bucketActions, "s3:ListBucketMultipartUploads")
+		}
+		document.Statement = append(document.Statement, pannoiv1beta1.PolicyStatement{
+			Effect:   "Allow",
+			Action:   bucketActions,
+			Resource: []string{bucketArn},
+		})
+		if len(objectActions) > 0 {
+			document.Statement = append(document.Statement, pannoiv1beta1.PolicyStatement{
+				Effect:   "Allow",
+				Action:   objectActions,
+				Resource: []string{objectArn},
+			})
+		}
+	}
+	return document, nil
+}
+
+// RenderPolicy returns the IAM JSON document of the policy, from the raw statement, the statement
+// source, the structured document or the preset, merged with the included policies and validated
+// before it is sent to minio.
+func RenderPolicy(ctx context.Context, c client.Client, policy *pannoiv1beta1.Policy) (string, error) {
+	return renderIncludingPolicy(ctx, c, policy, nil)
+}
+
+// renderIncludingPolicy renders the policy with its includes, chain holds the policies being
+// rendered to detect include cycles.
+func renderIncludingPolicy(ctx context.Context, c client.Client, policy *pannoiv1beta1.Policy, chain []string) (string, error) {
+	chain = append(append([]string{}, chain...), policy.Name)
+	if containsString(chain[:len(chain)-1], policy.Name) {
+		return "", fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+	}
+
+	sources := 0
+	if policy.Spec.Statement != "" {
+		sources++
+	}
+	if policy.Spec.StatementFrom != nil {
+		sources++
+	}
+	if policy.Spec.Document != nil {
+		sources++
+	}
+	if policy.Spec.Preset != nil {
+		sources++
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("only one of statement, statementFrom, document or preset can be set")
+	}
+	if sources == 0 && len(policy.Spec.Include) == 0 {
+		return "", fmt.Errorf("one of statement, statementFrom, document, preset or include must be set")
+	}
+
+	var docs []string
+	if sources == 1 {
+		doc, err := renderPolicySource(ctx, c, policy)
+		if err != nil {
+			return "", err
+		}
+		if len(policy.Spec.Include) == 0 {
+			return doc, nil
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, name := range policy.Spec.Include {
+		included := &pannoiv1beta1.Policy{}
+		err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: policy.Namespace}, included)
+		if err != nil {
+			return "", fmt.Errorf("failed to get included policy %s: %w", name, err)
+		}
+		doc, err := renderIncludingPolicy(ctx, c, included, chain)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+	return mergePolicyDocuments(docs)
+}
+
+// mergePolicyDocuments joins statements of the given policy documents into one document.
+func mergePolicyDocuments(docs []string) (string, error) {
+	merged := &pannoiv1beta1.PolicyDocument{Version: policyVersion}
+	for _, doc := range docs {
+		parsed, err := ParsePolicyDocument(doc)
+		if err != nil {
+			return "", err
+		}
+		merged.Statement = append(merged.Statement, parsed.Statement...)
+	}
+	return policyDocumentJSON(merged)
+}
+
+// renderPolicySource renders the single statement source of the policy.
+func renderPolicySource(ctx context.Context, c client.Client, policy *pannoiv1beta1.Policy) (string, error) {
+	document := policy.Spec.Document
+	if policy.Spec.Preset != nil {
+		var err error
+		document, err = presetDocument(ctx, c, policy.Namespace, policy.Spec.Preset)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if document != nil {
+		err := ValidatePolicyDocument(document)
+		if err != nil {
+			return "", err
+		}
+		return policyDocumentJSON(document)
+	}
+
+	statement := policy.Spec.Statement
+	if policy.Spec.StatementFrom != nil {
+		var err error
+		statement, err = statementSource(ctx, c, policy.Namespace, policy.Spec.StatementFrom)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	statement, err := renderStatement(ctx, c, policy, statement)
+	if err != nil {
+		return "", err
+	}
+	document, err = ParsePolicyDocument(statement)
+	if err != nil {
+		return "", err
+	}
+	err = ValidatePolicyDocument(document)
+	if err != nil {
+		return "", err
+	}
+	return statement, nil
+}
+
+// policyGuardrails returns the guardrails enabled by the comma separated env:POLICY_GUARDRAILS:
+// "forbid-wildcard" rejects wildcard actions on all buckets, "forbid-admin" rejects admin actions and
+// "namespace-buckets" restricts resources to the buckets declared in the Policy namespace.
+//
+// Patterns are matched against probe actions and resources, so e.g. "s3:*Object*" or "arn:aws:s3:::**"
+// are caught as well.
+func policyGuardrails() []string {
+	var guardrails []string
+	for _, guardrail := range strings.Split(os.Getenv("POLICY_GUARDRAILS"), ",") {
+		guardrail = strings.TrimSpace(guardrail)
+		if guardrail != "" {
+			guardrails = append(guardrails, guardrail)
+		}
+	}
+	return guardrails
+}
+
+// Probes the guardrail patterns are matched against. The resource probes name a bucket no namespace
+// declares, so only patterns covering all buckets match them.
+var (
+	guardrailActionProbes   = []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:ListBucket", "s3:DeleteBucket", "s3:PutBucketPolicy"}
+	guardrailResourceProbes = []string{"arn:aws:s3:::guardrail-probe", "arn:aws:s3:::guardrail-probe/object"}
+	guardrailAdminProbes    = []string{"admin:ServerInfo", "admin:CreateUser", "admin:AttachUserOrGroupPolicy", "admin:CreatePolicy"}
+)
+
+// builtinPolicies are the canned policies minio ships with.
+var builtinPolicies = []string{"consoleAdmin", "readwrite", "readonly", "writeonly", "diagnostics"}
+
+func matchesAny(pattern string, names []string) bool {
+	for _, name := range names {
+		if wildcard.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedPolicies returns the policy names which may be referenced without a Policy resource while
+// guardrails are enabled, from the comma separated env:POLICY_GUARDRAILS_ALLOWED_POLICIES.
+func allowedPolicies() []string {
+	var allowed []string
+	for _, name := range strings.Split(os.Getenv("POLICY_GUARDRAILS_ALLOWED_POLICIES"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed
+}
+
+// policyReferenceDenied returns why a policy referenced by name from a resource of the namespace, e.g.
+// from User, Group, PolicyBinding or AccessGrant resources, may not be attached, empty if it may.
+//
+// While guardrails are enabled, only policies declared by a Policy resource, whose documents are checked
+// by the guardrails, or allowlisted ones may be referenced, so built-in and otherwise created policies are
+// rejected. With namespace naming enabled, the policy has to be declared in the namespace.
+func policyReferenceDenied(ctx context.Context, c client.Client, namespace, name string) (string, error) {
+	if NamespaceNaming() != "" {
+		owned, err := policyOwned(ctx, c, namespace, name)
+		if err != nil {
+			return "", err
+		}
+		if !owned {
+			return "policy " + name + " is not declared by a Policy in namespace " + namespace, nil
+		}
+	}
+	if len(policyGuardrails()) == 0 || containsString(allowedPolicies(), name) {
+		return "", nil
+	}
+	if containsString(builtinPolicies, name) {
+		return "built-in policy " + name + " is forbidden by the guardrails", nil
+	}
+	claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.PolicyList{}, name)
+	if err != nil {
+		return "", err
+	}
+	if claimant == nil {
+		return "policy " + name + " is not declared by a Policy resource and cannot be checked by the guardrails", nil
+	}
+	return "", nil
+}
+
+// CheckPolicyReferences validates the policies referenced by name from a resource of the namespace against
+// the guardrails and the namespace naming.
+func CheckPolicyReferences(ctx context.Context, c client.Client, namespace string, names []string) error {
+	for _, name := range names {
+		denied, err := policyReferenceDenied(ctx, c, namespace, name)
+		if err != nil {
+			return err
+		}
+		if denied != "" {
+			return fmt.Errorf("%s", denied)
+		}
+	}
+	return nil
+}
+
+// checkedPolicies returns the policies referenced from a resource of the namespace which are not denied
+// by policyReferenceDenied.
+func checkedPolicies(ctx context.Context, c client.Client, namespace string, names []string) ([]string, error) {
+	var checked []string
+	for _, name := range names {
+		denied, err := policyReferenceDenied(ctx, c, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if denied == "" {
+			checked = append(checked, name)
+		}
+	}
+	return checked, nil
+}
+
+// CheckPolicyGuardrails validates the Allow statements of a policy document declared in the namespace
+// against the operator guardrails and the namespace prefix.
+func CheckPolicyGuardrails(ctx context.Context, c client.Client, namespace, document string) error {
+	err := CheckNamespaceResources(ctx, c, namespace, document)
+	if err != nil {
+		return err
+	}
+
+	guardrails := policyGuardrails()
+	if len(guardrails) == 0 {
+		return nil
+	}
+
+	parsed, err := ParsePolicyDocument(document)
+	if err != nil {
+		return err
+	}
+
+	var namespaceBuckets []string
+	if containsString(guardrails, "namespace-buckets") {
+		buckets := &pannoiv1beta1.BucketList{}
+		err = c.List(ctx, buckets, client.InNamespace(namespace))
+		if err != nil {
+			return err
+		}
+		for _, bucket := range buckets.Items {
+			namespaceBuckets = append(namespaceBuckets, BucketName(&bucket))
+		}
+	}
+
+	for i, statement := range parsed.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+
+		if containsString(guardrails, "forbid-wildcard") {
+			allActions := len(statement.NotAction) > 0
+			for _, action := range statement.Action {
+				if strings.ContainsAny(action, "*?") && matchesAny(action, guardrailActionProbes) {
+					allActions = true
+				}
+			}
+			allBuckets := len(statement.NotResource) > 0
+			for _, resource := range statement.Resource {
+				if matchesAny(resource, guardrailResourceProbes) {
+					allBuckets = true
+				}
+			}
+			if allActions && allBuckets {
+				return fmt.Errorf("Statement[%d]: wildcard s3 actions on all buckets are forbidden", i)
+			}
+		}
+
+		if containsString(guardrails, "forbid-admin") {
+			for _, action := range statement.Action {
+				if strings.HasPrefix(action, "admin:") || matchesAny(action, guardrailAdminProbes) {
+					return fmt.Errorf("Statement[%d]: admin action %q is forbidden", i, action)
+				}
+			}
+			if len(statement.NotAction) > 0 {
+				return fmt.Errorf("Statement[%d]: NotAction is forbidden, it grants admin actions", i)
+			}
+		}
+
+		if containsString(guardrails, "namespace-buckets") {
+			if len(statement.NotResource) > 0 {
+				return fmt.Errorf("Statement[%d]: NotResource is forbidden, it grants buckets of other namespaces", i)
+			}
+			for _, resource := range statement.Resource {
+				bucket := strings.SplitN(strings.TrimPrefix(resource, "arn:aws:s3:::"), "/", 2)[0]
+				if !strings.HasPrefix(resource, "arn:aws:s3:::") || !containsString(namespaceBuckets, bucket) {
+					return fmt.Errorf("Statement[%d]: resource %q is not a bucket of namespace %s", i, resource, namespace)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// statementTemplateData is available in policy statement templates.
+type statementTemplateData struct {
+	Name      string
+	Namespace string
+	Tenant    string
+}
+
+// statementSource reads the policy statement from the referenced ConfigMap or Secret key.
+func statementSource(ctx context.Context, c client.Client, namespace string, source *pannoiv1beta1.StatementSource) (string, error) {
+	if ref := source.ConfigMapKeyRef; ref != nil {
+		configMap := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, configMap)
+		if err != nil {
+			return "", fmt.Errorf("failed to get configmap %s: %w", ref.Name, err)
+		}
+		statement, ok := configMap.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found in configmap %s", ref.Key, ref.Name)
+		}
+		return statement, nil
+	}
+
+	if ref := source.SecretKeyRef; ref != nil {
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret)
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret %s: %w", ref.Name, err)
+		}
+		statement, ok := secret.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+		}
+		return string(statement), nil
+	}
+
+	return "", fmt.Errorf("one of configMapKeyRef or secretKeyRef must be set in statementFrom")
+}
+
+// renderStatement renders the policy statement as template when spec.template is set, the "bucket"
+// function resolves the minio bucket name of a Bucket resource in the policy namespace. Other statements
+// are returned as they are.
+func renderStatement(ctx context.Context, c client.Client, policy *pannoiv1beta1.Policy, statement string) (string, error) {
+	if !policy.Spec.Template {
+		return statement, nil
+	}
+
+	funcs := template.FuncMap{
+		"bucket": func(name string) (string, error) {
+			bucket := &pannoiv1beta1.Bucket{}
+			err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: policy.Namespace}, bucket)
+			if err != nil {
+				return "", err
+			}
+			return BucketName(bucket), nil
+		},
+	}
+
+	tmpl, err := template.New(policy.Name).Funcs(funcs).Option("missingkey=error").Parse(statement)
+	if err != nil {
+		return "", fmt.Errorf("invalid statement template: %w", err)
+	}
+
+	out := &bytes.Buffer{}
+	err = tmpl.Execute(out, statementTemplateData{
+		Name:      policy.Spec.Name,
+		Namespace: policy.Namespace,
+		Tenant:    os.Getenv("MINIO_TENANT"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render statement template: %w", err)
+	}
+	return out.String(), nil
 }
 
 func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -55,8 +576,8 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			Status: "Failed",
 			Reason: "Failed to connect to Minio",
 		}
-		policy.Status.Conditions = append(policy.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, policy)
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
 		if err != nil {
 			log.Error(err, "Failed to update status conditions")
 			return ctrl.Result{}, err
@@ -65,14 +586,93 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
-	err = mc.AddCannedPolicy(ctx, policy.Spec.Name, []byte(policy.Spec.Statement))
+	claimant, err := conflictingClaim(ctx, r.Client, &pannoiv1beta1.PolicyList{}, policy, policy.Spec.Name)
 	if err != nil {
+		log.Error(err, "Failed to check claims of policy name: "+policy.Spec.Name)
+		return ctrl.Result{}, err
+	}
+	if claimant != "" {
 		conditions := metav1.Condition{
-			Status: "Failed",
-			Reason: "Failed create policy",
+			Status:  "Failed",
+			Reason:  "NameConflict",
+			Message: "Policy name " + policy.Spec.Name + " is claimed by " + claimant,
+		}
+		policy.Status.NameConflict = claimant
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policy name " + policy.Spec.Name + " is claimed by " + claimant)
+		return ctrl.Result{}, nil
+	}
+	policy.Status.NameConflict = ""
+
+	err = CheckNamespaceName(ctx, r.Client, policy.Namespace, "policy", policy.Spec.Name)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NamespacePrefix",
+			Message: err.Error(),
+		}
+		policy.Status.Error = err.Error()
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policy name is not prefixed with namespace prefix: " + policy.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	document, err := RenderPolicy(ctx, r.Client, policy)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Invalid policy document",
+			Message: err.Error(),
+		}
+		policy.Status.Error = err.Error()
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Invalid policy document: " + policy.Spec.Name + ": " + policy.Status.Error)
+		return ctrl.Result{}, nil
+	}
+
+	err = CheckPolicyGuardrails(ctx, r.Client, policy.Namespace, document)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Policy violates guardrails",
+			Message: err.Error(),
+		}
+		policy.Status.Error = err.Error()
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policy violates guardrails: " + policy.Spec.Name + ": " + policy.Status.Error)
+		return ctrl.Result{}, nil
+	}
+
+	err = mc.AddCannedPolicy(ctx, policy.Spec.Name, []byte(document))
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed create policy",
+			Message: err.Error(),
 		}
-		policy.Status.Conditions = append(policy.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, policy)
+		policy.Status.Error = err.Error()
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
 		if err != nil {
 			log.Error(err, "Failed to update status conditions")
 			return ctrl.Result{}, err
@@ -81,23 +681,222 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	users, groups, err := r.entities.get(policy.Spec.Name, time.Now(), func() (map[string][]string, map[string][]string, error) {
+		return policyEntities(ctx, mc)
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed to list policy entities",
+			Message: err.Error(),
+		}
+		setCondition(&policy.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, policy)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to list entities of policy: "+policy.Spec.Name)
+		return ctrl.Result{RequeueAfter: policyEntitiesRefreshPeriod}, nil
+	}
+	policy.Status.Users = users
+	policy.Status.Groups = groups
+	policy.Status.UserCount = len(users)
+	policy.Status.GroupCount = len(groups)
+
 	conditions := metav1.Condition{
 		Status: "Ready",
 		Reason: "Ready",
 	}
-	policy.Status.Conditions = append(policy.Status.Conditions, conditions)
-	err = r.Status().Update(ctx, policy)
+	policy.Status.Error = ""
+	setCondition(&policy.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, policy)
 	if err != nil {
 		log.Error(err, "Failed to update Bucket status")
 		return ctrl.Result{}, err
 	}
 
 	log.Info("Policy was created: " + policy.Spec.Name)
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: policyEntitiesRefreshPeriod}, nil
+}
+
+// policyEntities returns the minio users and groups of every policy, keyed by policy name.
+func policyEntities(ctx context.Context, mc *madmin.AdminClient) (map[string][]string, map[string][]string, error) {
+	users := map[string][]string{}
+	userInfos, err := mc.ListUsers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for user, info := range userInfos {
+		for _, name := range strings.Split(info.PolicyName, ",") {
+			if name != "" {
+				users[name] = append(users[name], user)
+			}
+		}
+	}
+
+	groups := map[string][]string{}
+	groupNames, err := mc.ListGroups(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, group := range groupNames {
+		desc, err := mc.GetGroupDescription(ctx, group)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range strings.Split(desc.Policy, ",") {
+			if name != "" {
+				groups[name] = append(groups[name], group)
+			}
+		}
+	}
+
+	for name := range users {
+		sort.Strings(users[name])
+	}
+	for name := range groups {
+		sort.Strings(groups[name])
+	}
+	return users, groups, nil
+}
+
+// findPoliciesForConfigMap maps a ConfigMap to the Policies reading their statement from it.
+func (r *PolicyReconciler) findPoliciesForConfigMap(ctx context.Context, configMap client.Object) []reconcile.Request {
+	return r.findPoliciesByField(ctx, configMap, statementConfigMapField)
+}
+
+// findPoliciesForSecret maps a Secret to the Policies reading their statement from it.
+func (r *PolicyReconciler) findPoliciesForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	return r.findPoliciesByField(ctx, secret, statementSecretField)
+}
+
+func (r *PolicyReconciler) findPoliciesByField(ctx context.Context, obj client.Object, field string) []reconcile.Request {
+	policies := &pannoiv1beta1.PolicyList{}
+	err := r.List(ctx, policies, client.InNamespace(obj.GetNamespace()), client.MatchingFields{field: obj.GetName()})
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for i, item := range policies.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+			},
+		})
+		requests = append(requests, r.findPoliciesForIncluded(ctx, &policies.Items[i])...)
+	}
+	return requests
+}
+
+// findPoliciesForIncluded maps a Policy to the Policies including it, directly or through other includes.
+func (r *PolicyReconciler) findPoliciesForIncluded(ctx context.Context, included client.Object) []reconcile.Request {
+	policies := &pannoiv1beta1.PolicyList{}
+	err := r.List(ctx, policies, client.InNamespace(included.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	found := []string{}
+	pending := []string{included.GetName()}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		for _, item := range policies.Items {
+			if !containsString(item.Spec.Include, name) || containsString(found, item.Name) {
+				continue
+			}
+			found = append(found, item.Name)
+			pending = append(pending, item.Name)
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// findPoliciesForBucket maps a Bucket to the Policies referencing it in presets
+// or possibly in statement templates.
+func (r *PolicyReconciler) findPoliciesForBucket(ctx context.Context, bucket client.Object) []reconcile.Request {
+	policies := &pannoiv1beta1.PolicyList{}
+	err := r.List(ctx, policies, client.InNamespace(bucket.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for i, item := range policies.Items {
+		referenced := item.Spec.Template
+		if item.Spec.Preset != nil {
+			for _, target := range item.Spec.Preset.Buckets {
+				if target.BucketRef == bucket.GetName() {
+					referenced = true
+				}
+			}
+		}
+		if referenced {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+			requests = append(requests, r.findPoliciesForIncluded(ctx, &policies.Items[i])...)
+		}
+	}
+	return requests
+}
+
+// findPoliciesWithSameName maps a Policy to the other Policies claiming the same minio name.
+func (r *PolicyReconciler) findPoliciesWithSameName(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy := obj.(*pannoiv1beta1.Policy)
+	return sameNameRequests(ctx, r.Client, &pannoiv1beta1.PolicyList{}, policy, policy.Spec.Name)
 }
 
 func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.Policy{}, statementConfigMapField, func(obj client.Object) []string {
+		policy := obj.(*pannoiv1beta1.Policy)
+		if policy.Spec.StatementFrom == nil || policy.Spec.StatementFrom.ConfigMapKeyRef == nil {
+			return nil
+		}
+		return []string{policy.Spec.StatementFrom.ConfigMapKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.Policy{}, statementSecretField, func(obj client.Object) []string {
+		policy := obj.(*pannoiv1beta1.Policy)
+		if policy.Spec.StatementFrom == nil || policy.Spec.StatementFrom.SecretKeyRef == nil {
+			return nil
+		}
+		return []string{policy.Spec.StatementFrom.SecretKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.Policy{}, specNameField, func(obj client.Object) []string {
+		return []string{obj.(*pannoiv1beta1.Policy).Spec.Name}
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&pannoiv1beta1.Policy{}).
+		For(&pannoiv1beta1.Policy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findPoliciesForIncluded)).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findPoliciesWithSameName)).
+		Watches(&pannoiv1beta1.Bucket{}, handler.EnqueueRequestsFromMapFunc(r.findPoliciesForBucket)).
+		// Only metadata of ConfigMaps and Secrets is cached, statements are read from the API server.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findPoliciesForConfigMap), builder.OnlyMetadata).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findPoliciesForSecret), builder.OnlyMetadata).
 		Complete(r)
 }
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestPolicyEntitySnapshot(t *testing.T) {
+	fetches := 0
+	fetch := func() (map[string][]string, map[string][]string, error) {
+		fetches++
+		return map[string][]string{"readonly": {"alice", "bob"}}, map[string][]string{"readonly": {"developers"}}, nil
+	}
+
+	snapshot := &policyEntitySnapshot{}
+	now := time.Now()
+	for _, name := range []string{"readonly", "readwrite"} {
+		_, _, err := snapshot.get(name, now, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected entities of all policies to be listed once, got %d fetches", fetches)
+	}
+
+	users, groups, err := snapshot.get("readonly", now.Add(policyEntitiesRefreshPeriod), fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected entities to be listed again after the refresh period, got %d fetches", fetches)
+	}
+	if !reflect.DeepEqual(users, []string{"alice", "bob"}) || !reflect.DeepEqual(groups, []string{"developers"}) {
+		t.Errorf("expected users and groups of the policy, got %v %v", users, groups)
+	}
+
+	users, _, err = snapshot.get("readwrite", now.Add(policyEntitiesRefreshPeriod), fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users == nil || len(users) != 0 {
+		t.Errorf("expected empty users of unattached policy, got %v", users)
+	}
+}
+
+func TestCheckPolicyGuardrailsPatterns(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-wildcard,forbid-admin")
+	c := newFakeClient()
+
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+		allowed  bool
+	}{
+		{"all actions on all buckets", "s3:*", "arn:aws:s3:::*", false},
+		{"double star resource", "s3:*", "arn:aws:s3:::**", false},
+		{"question mark action", "?*", "arn:aws:s3:::team-a-data/*", false},
+		{"object actions on all buckets", "s3:*Object*", "*", false},
+		{"object actions on own bucket", "s3:*Object*", "arn:aws:s3:::team-a-data/*", true},
+		{"single action on all buckets", "s3:GetObject", "arn:aws:s3:::*", true},
+		{"admin pattern", "adm*", "arn:aws:s3:::team-a-data", false},
+		{"admin action", "admin:ServerInfo", "arn:aws:s3:::team-a-data", false},
+	}
+	for _, tt := range tests {
+		document := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["` + tt.action + `"],"Resource":["` + tt.resource + `"]}]}`
+		err := CheckPolicyGuardrails(context.Background(), c, "team-a", document)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+}
+
+func TestCheckPolicyReferences(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-a-read"},
+		},
+	)
+
+	err := CheckPolicyReferences(context.Background(), c, "team-a", []string{"consoleAdmin", "unmanaged"})
+	if err != nil {
+		t.Errorf("expected references to be unchecked without guardrails, got %v", err)
+	}
+
+	t.Setenv("POLICY_GUARDRAILS", "forbid-admin")
+	t.Setenv("POLICY_GUARDRAILS_ALLOWED_POLICIES", "readonly")
+	tests := []struct {
+		name    string
+		allowed bool
+	}{
+		{"team-a-read", true},
+		{"readonly", true},
+		{"consoleAdmin", false},
+		{"readwrite", false},
+		{"unmanaged", false},
+	}
+	for _, tt := range tests {
+		err = CheckPolicyReferences(context.Background(), c, "team-a", []string{tt.name})
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+}
+
+func TestSubjectPoliciesSkipsPoliciesDeniedByGuardrails(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-admin")
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice", Policies: []string{"consoleAdmin", "team-a-read"}},
+		},
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicyBindingSpec{
+				Policies: []string{"readwrite"},
+				Subjects: []pannoiv1beta1.Subject{{Kind: "User", Name: "alice"}},
+			},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "team-a-read"},
+		},
+	)
+
+	policies, err := subjectPolicies(context.Background(), c, pannoiv1beta1.Subject{Kind: "User", Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(policies, []string{"team-a-read"}) {
+		t.Errorf("expected only the declared policy, got %v", policies)
+	}
+}
+
+func TestRenderStatementTemplateOptIn(t *testing.T) {
+	c := newFakeClient(&pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "team-a.data"},
+	})
+	statement := `{"Resource":["arn:aws:s3:::{{ bucket "data" }}/*"]}`
+	policy := &pannoiv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "team-a"}}
+
+	out, err := renderStatement(context.Background(), c, policy, statement)
+	if err != nil || out != statement {
+		t.Errorf("expected a statement without template to be kept, got %s (%v)", out, err)
+	}
+
+	policy.Spec.Template = true
+	out, err = renderStatement(context.Background(), c, policy, statement)
+	if err != nil || out != `{"Resource":["arn:aws:s3:::team-a.data/*"]}` {
+		t.Errorf("expected the template to be rendered, got %s (%v)", out, err)
+	}
+}
+
+func TestFindPoliciesForBucket(t *testing.T) {
+	r := &PolicyReconciler{Client: newFakeClient(
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "templated", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "templated", Template: true},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "literal", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicySpec{Name: "literal", Statement: `{"Resource":["arn:aws:s3:::{{ x }}"]}`},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "preset", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicySpec{Name: "preset", Preset: &pannoiv1beta1.PolicyPreset{
+				Type: "readonly", Buckets: []pannoiv1beta1.PresetBucket{{BucketRef: "data"}},
+			}},
+		},
+	)}
+	bucket := &pannoiv1beta1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"}}
+
+	var names []string
+	for _, request := range r.findPoliciesForBucket(context.Background(), bucket) {
+		names = append(names, request.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"preset", "templated"}) {
+		t.Errorf("expected only templated and preset policies, got %v", names)
+	}
+}
+
+func TestFindPoliciesForStatementSource(t *testing.T) {
+	r := &PolicyReconciler{Client: newFakeClient(
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "from-configmap", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicySpec{Name: "from-configmap", StatementFrom: &pannoiv1beta1.StatementSource{
+				ConfigMapKeyRef: &pannoiv1beta1.ConfigMapKeyReference{Name: "policies", Key: "read.json"},
+			}},
+		},
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "from-secret", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicySpec{Name: "from-secret", StatementFrom: &pannoiv1beta1.StatementSource{
+				SecretKeyRef: &pannoiv1beta1.SecretKeyReference{Name: "policies", Key: "write.json"},
+			}},
+		},
+	)}
+
+	// ConfigMaps and Secrets are watched by metadata only.
+	source := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "policies", Namespace: "team-a"}}
+	requests := r.findPoliciesForConfigMap(context.Background(), source)
+	if len(requests) != 1 || requests[0].Name != "from-configmap" {
+		t.Errorf("expected only the policy reading the configmap, got %v", requests)
+	}
+	requests = r.findPoliciesForSecret(context.Background(), source)
+	if len(requests) != 1 || requests[0].Name != "from-secret" {
+		t.Errorf("expected only the policy reading the secret, got %v", requests)
+	}
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+// policyVersion is the only IAM policy language version supported by minio.
+const policyVersion = "2012-10-17"
+
+// conditionOperators are the condition operators supported by minio, they may be prefixed
+// with ForAnyValue: or ForAllValues: and suffixed with IfExists.
+var conditionOperators = []string{
+	"StringEquals", "StringNotEquals", "StringEqualsIgnoreCase", "StringNotEqualsIgnoreCase",
+	"StringLike", "StringNotLike",
+	"NumericEquals", "NumericNotEquals", "NumericLessThan", "NumericLessThanEquals",
+	"NumericGreaterThan", "NumericGreaterThanEquals",
+	"DateEquals", "DateNotEquals", "DateLessThan", "DateLessThanEquals",
+	"DateGreaterThan", "DateGreaterThanEquals",
+	"BinaryEquals", "IpAddress", "NotIpAddress", "Null", "Bool",
+}
+
+// actionServices are the services of minio policy actions, e.g. s3:GetObject or admin:ServerInfo.
+var actionServices = []string{"s3", "admin", "kms", "sts"}
+
+// stringList is an IAM value which is either a single string or a list of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var list []interface{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		list = []interface{}{value}
+	}
+
+	*l = nil
+	for _, value := range list {
+		switch v := value.(type) {
+		case string:
+			*l = append(*l, v)
+		case bool, float64:
+			*l = append(*l, fmt.Sprint(v))
+		default:
+			return fmt.Errorf("expected string or list of strings, got %s", string(data))
+		}
+	}
+	return nil
+}
+
+// iamPolicy and iamStatement are the IAM JSON layout of PolicyDocument and PolicyStatement.
+type iamPolicy struct {
+	Version   string         `json:"Version"`
+	ID        string         `json:"Id,omitempty"`
+	Statement []iamStatement `json:"Statement"`
+}
+
+type iamStatement struct {
+	Sid         string                           `json:"Sid,omitempty"`
+	Effect      string                           `json:"Effect"`
+	Action      stringList                       `json:"Action,omitempty"`
+	NotAction   stringList                       `json:"NotAction,omitempty"`
+	Resource    stringList                       `json:"Resource,omitempty"`
+	NotResource stringList                       `json:"NotResource,omitempty"`
+	Condition   map[string]map[string]stringList `json:"Condition,omitempty"`
+}
+
+// ParsePolicyDocument parses an IAM policy JSON document. Fields which are not validated, like Id or
+// Principal of statements, are ignored as minio accepts them.
+func ParsePolicyDocument(raw string) (*pannoiv1beta1.PolicyDocument, error) {
+	parsed := iamPolicy{}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid policy JSON: %w", err)
+	}
+
+	doc := &pannoiv1beta1.PolicyDocument{Version: parsed.Version}
+	for _, st := range parsed.Statement {
+		statement := pannoiv1beta1.PolicyStatement{
+			Sid:         st.Sid,
+			Effect:      st.Effect,
+			Action:      st.Action,
+			NotAction:   st.NotAction,
+			Resource:    st.Resource,
+			NotResource: st.NotResource,
+		}
+		if st.Condition != nil {
+			statement.Condition = map[string]map[string][]string{}
+			for operator, values := range st.Condition {
+				statement.Condition[operator] = map[string][]string{}
+				for key, value := range values {
+					statement.Condition[operator][key] = value
+				}
+			}
+		}
+		doc.Statement = append(doc.Statement, statement)
+	}
+	return doc, nil
+}
+
+// ValidatePolicyDocument checks the document against the IAM rules applied by minio.
+func ValidatePolicyDocument(d *pannoiv1beta1.PolicyDocument) error {
+	if d.Version != "" && d.Version != policyVersion {
+		return fmt.Errorf("invalid Version %q, expected %q", d.Version, policyVersion)
+	}
+	if len(d.Statement) == 0 {
+		return fmt.Errorf("policy has no Statement")
+	}
+	for i := range d.Statement {
+		if err := validatePolicyStatement(&d.Statement[i]); err != nil {
+			return fmt.Errorf("Statement[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validatePolicyStatement(s *pannoiv1beta1.PolicyStatement) error {
+	if s.Effect != "Allow" && s.Effect != "Deny" {
+		return fmt.Errorf("invalid Effect %q, expected Allow or Deny", s.Effect)
+	}
+
+	if len(s.Action) > 0 && len(s.NotAction) > 0 {
+		return fmt.Errorf("Action and NotAction are mutually exclusive")
+	}
+	actions := append(append([]string{}, s.Action...), s.NotAction...)
+	if len(actions) == 0 {
+		return fmt.Errorf("Action must not be empty")
+	}
+	needsResource := false
+	for _, action := range actions {
+		service, _, found := strings.Cut(action, ":")
+		if action != "*" && (!found || !containsString(actionServices, service)) {
+			return fmt.Errorf("invalid Action %q", action)
+		}
+		if action == "*" || service == "s3" {
+			needsResource = true
+		}
+	}
+
+	if len(s.Resource) > 0 && len(s.NotResource) > 0 {
+		return fmt.Errorf("Resource and NotResource are mutually exclusive")
+	}
+	resources := append(append([]string{}, s.Resource...), s.NotResource...)
+	if needsResource && len(resources) == 0 {
+		return fmt.Errorf("Resource must not be empty for s3 actions")
+	}
+	for _, resource := range resources {
+		if resource != "*" && !strings.HasPrefix(resource, "arn:aws:s3:::") && !strings.HasPrefix(resource, "arn:minio:") {
+			return fmt.Errorf("invalid Resource %q", resource)
+		}
+	}
+
+	for operator, values := range s.Condition {
+		name := strings.TrimPrefix(strings.TrimPrefix(operator, "ForAnyValue:"), "ForAllValues:")
+		name = strings.TrimSuffix(name, "IfExists")
+		if !containsString(conditionOperators, name) {
+			return fmt.Errorf("invalid Condition operator %q", operator)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("Condition %q has no keys", operator)
+		}
+	}
+	return nil
+}
+
+// policyDocumentJSON renders the document in IAM policy layout.
+func policyDocumentJSON(d *pannoiv1beta1.PolicyDocument) (string, error) {
+	version := d.Version
+	if version == "" {
+		version = policyVersion
+	}
+
+	policy := iamPolicy{Version: version}
+	for _, st := range d.Statement {
+		statement := iamStatement{
+			Sid:         st.Sid,
+			Effect:      st.Effect,
+			Action:      st.Action,
+			NotAction:   st.NotAction,
+			Resource:    st.Resource,
+			NotResource: st.NotResource,
+		}
+		if st.Condition != nil {
+			statement.Condition = map[string]map[string]stringList{}
+			for operator, values := range st.Condition {
+				statement.Condition[operator] = map[string]stringList{}
+				for key, value := range values {
+					statement.Condition[operator][key] = value
+				}
+			}
+		}
+		policy.Statement = append(policy.Statement, statement)
+	}
+
+	out, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"testing"
+)
+
+func TestParsePolicyDocumentIgnoresUnknownFields(t *testing.T) {
+	document, err := ParsePolicyDocument(`{
+		"Version": "2012-10-17",
+		"Id": "team-data",
+		"Comment": "read access",
+		"Statement": [{
+			"Sid": "Read",
+			"Effect": "Allow",
+			"Principal": {"AWS": ["*"]},
+			"Action": "s3:GetObject",
+			"Resource": "arn:aws:s3:::data/*"
+		}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidatePolicyDocument(document)
+	if err != nil {
+		t.Errorf("expected a document accepted by minio to be valid, got %v", err)
+	}
+	if len(document.Statement) != 1 || document.Statement[0].Action[0] != "s3:GetObject" {
+		t.Errorf("unexpected statements %+v", document.Statement)
+	}
+}
+
+func TestValidatePolicyDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		valid    bool
+	}{
+		{"valid", `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:*"],"Resource":["arn:aws:s3:::data/*"]}]}`, true},
+		{"admin action without resource", `{"Statement":[{"Effect":"Allow","Action":"admin:ServerInfo"}]}`, true},
+		{"version", `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`, false},
+		{"no statement", `{"Version":"2012-10-17","Statement":[]}`, false},
+		{"effect", `{"Statement":[{"Effect":"Permit","Action":"s3:*","Resource":"*"}]}`, false},
+		{"action", `{"Statement":[{"Effect":"Allow","Action":"GetObject","Resource":"*"}]}`, false},
+		{"s3 action without resource", `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject"}]}`, false},
+		{"resource", `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"data/*"}]}`, false},
+		{"condition operator", `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*","Condition":{"StringMatches":{"aws:username":"a"}}}]}`, false},
+	}
+	for _, tt := range tests {
+		document, err := ParsePolicyDocument(tt.document)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err = ValidatePolicyDocument(document)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestPolicyDocumentJSON(t *testing.T) {
+	document, err := ParsePolicyDocument(`{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::data/*"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := policyDocumentJSON(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::data/*"]}]}`
+	if out != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
@@ -0,0 +1,514 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+type PolicyBindingReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func (r *PolicyBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	binding := &pannoiv1beta1.PolicyBinding{}
+	err := r.Get(ctx, req.NamespacedName, binding)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("PolicyBinding resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get PolicyBinding resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&binding.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, binding)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	if !binding.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(binding, minioFinalizer) {
+			for _, subject := range append(binding.Spec.Subjects, binding.Status.Subjects...) {
+				err = applySubjectPolicies(ctx, r.Client, mc, subject)
+				if err != nil {
+					log.Error(err, "Failed to detach policies from "+subject.Kind+" "+subject.Name)
+					return ctrl.Result{}, err
+				}
+			}
+			controllerutil.RemoveFinalizer(binding, minioFinalizer)
+			err = r.Update(ctx, binding)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("PolicyBinding was deleted: " + binding.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(binding, minioFinalizer) {
+		controllerutil.AddFinalizer(binding, minioFinalizer)
+		err = r.Update(ctx, binding)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	policies, err := bindingPolicies(ctx, r.Client, binding)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get referenced policy",
+		}
+		setCondition(&binding.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, binding)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get referenced policies of binding: "+binding.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	err = CheckPolicyReferences(ctx, r.Client, binding.Namespace, policies)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Policy reference denied",
+			Message: err.Error(),
+		}
+		setCondition(&binding.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, binding)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policies of binding are denied: " + binding.Name)
+		return ctrl.Result{}, nil
+	}
+
+	for _, subject := range binding.Spec.Subjects {
+		owned, err := subjectOwned(ctx, r.Client, binding.Namespace, subject)
+		if err != nil {
+			log.Error(err, "Failed to check owner of "+subject.Kind+" "+subject.Name)
+			return ctrl.Result{}, err
+		}
+		if !owned {
+			message := subject.Kind + " " + subject.Name + " is not managed by a resource in namespace " + binding.Namespace
+			if subject.Kind != "User" && subject.Kind != "Group" {
+				message = "namespace " + binding.Namespace + " is not allowed to bind " + subject.Kind + " subjects"
+			}
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Subject is not owned by namespace",
+				Message: message,
+			}
+			setCondition(&binding.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, binding)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Info(subject.Kind + " " + subject.Name + " is not owned by namespace " + binding.Namespace)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Subjects dropped from the spec are reconciled as well, so their policies get detached.
+	subjects := append([]pannoiv1beta1.Subject{}, binding.Spec.Subjects...)
+	for _, subject := range binding.Status.Subjects {
+		if !containsSubject(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	for _, subject := range subjects {
+		err = applySubjectPolicies(ctx, r.Client, mc, subject)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to attach policy",
+			}
+			setCondition(&binding.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, binding)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to attach policies to "+subject.Kind+" "+subject.Name)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	binding.Status.Policies = policies
+	binding.Status.Subjects = binding.Spec.Subjects
+	setCondition(&binding.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, binding)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("PolicyBinding was reconciled: " + binding.Name)
+	return ctrl.Result{}, nil
+}
+
+func containsSubject(list []pannoiv1beta1.Subject, s pannoiv1beta1.Subject) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
+
+// externalSubjectNamespaces returns the namespaces allowed to bind LDAP and OpenID identities, from the
+// comma separated env:EXTERNAL_SUBJECT_NAMESPACES.
+func externalSubjectNamespaces() []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(os.Getenv("EXTERNAL_SUBJECT_NAMESPACES"), ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// subjectOwned reports whether the minio user or group of the subject is claimed by a User, BucketAccess
+// or Group resource of the namespace, so a namespace cannot grant policies to identities of others.
+// LDAP and OIDC identities live in the directory and are not owned by a namespace, only the namespaces
+// allowed by the operator may bind them.
+func subjectOwned(ctx context.Context, c client.Client, namespace string, subject pannoiv1beta1.Subject) (bool, error) {
+	var claimant client.Object
+	var err error
+	switch subject.Kind {
+	case "User":
+		claimant, err = UserNameClaimant(ctx, c, subject.Name)
+	case "Group":
+		claimant, err = NameClaimant(ctx, c, &pannoiv1beta1.GroupList{}, subject.Name)
+	default:
+		return containsString(externalSubjectNamespaces(), namespace), nil
+	}
+	if err != nil || claimant == nil {
+		return false, err
+	}
+	return claimant.GetNamespace() == namespace, nil
+}
+
+// policyOwned reports whether the minio policy is declared by a Policy resource of the namespace.
+func policyOwned(ctx context.Context, c client.Client, namespace, name string) (bool, error) {
+	claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.PolicyList{}, name)
+	if err != nil || claimant == nil {
+		return false, err
+	}
+	return claimant.GetNamespace() == namespace, nil
+}
+
+// bindingPolicies returns the policy names of the binding, resolving Policy references
+// in the binding namespace.
+func bindingPolicies(ctx context.Context, c client.Client, binding *pannoiv1beta1.PolicyBinding) ([]string, error) {
+	policies := append([]string{}, binding.Spec.Policies...)
+	for _, ref := range binding.Spec.PolicyRefs {
+		policy := &pannoiv1beta1.Policy{}
+		err := c.Get(ctx, types.NamespacedName{Name: ref, Namespace: binding.Namespace}, policy)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy.Spec.Name)
+	}
+	return policies, nil
+}
+
+// subjectPolicies collects all policies which should be attached to a minio user or group:
+// the ones declared on its User or Group resources, the ones granted by PolicyBindings
+// and by currently active AccessGrants. Policies referenced against the guardrails are left out.
+func subjectPolicies(ctx context.Context, c client.Client, subject pannoiv1beta1.Subject) ([]string, error) {
+	var policies []string
+
+	switch subject.Kind {
+	case "User":
+		users := &pannoiv1beta1.UserList{}
+		err := c.List(ctx, users)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users.Items {
+			if user.Spec.Name != subject.Name {
+				continue
+			}
+			checked, err := checkedPolicies(ctx, c, user.Namespace, user.Spec.Policies)
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, checked...)
+			if user.Spec.InlinePolicy != "" && user.DeletionTimestamp.IsZero() {
+				policies = append(policies, inlinePolicyName(user.Spec.Name))
+			}
+			if user.Spec.Home != nil && user.DeletionTimestamp.IsZero() {
+				policies = append(policies, homePolicyName(user.Spec.Home.Bucket))
+			}
+		}
+		accesses := &pannoiv1beta1.BucketAccessList{}
+		err = c.List(ctx, accesses)
+		if err != nil {
+			return nil, err
+		}
+		for i := range accesses.Items {
+			access := &accesses.Items[i]
+			username := bucketAccessUser(access)
+			if username == subject.Name && access.DeletionTimestamp.IsZero() {
+				policies = append(policies, bucketAccessPolicyName(username))
+			}
+		}
+	case "Group":
+		// Groups not owning the name are not reconciled, their policies are not attached.
+		claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.GroupList{}, subject.Name)
+		if err != nil {
+			return nil, err
+		}
+		if group, ok := claimant.(*pannoiv1beta1.Group); ok && group.DeletionTimestamp.IsZero() {
+			checked, err := checkedPolicies(ctx, c, group.Namespace, group.Spec.Policies)
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, checked...)
+		}
+	}
+
+	bindings := &pannoiv1beta1.PolicyBindingList{}
+	err := c.List(ctx, bindings)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bindings.Items {
+		binding := &bindings.Items[i]
+		if !binding.DeletionTimestamp.IsZero() || !containsSubject(binding.Spec.Subjects, subject) {
+			continue
+		}
+		owned, err := subjectOwned(ctx, c, binding.Namespace, subject)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			continue
+		}
+		bound, err := bindingPolicies(ctx, c, binding)
+		if err != nil {
+			return nil, err
+		}
+		checked, err := checkedPolicies(ctx, c, binding.Namespace, bound)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, checked...)
+	}
+
+	grants := &pannoiv1beta1.AccessGrantList{}
+	err = c.List(ctx, grants)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range grants.Items {
+		grant := &grants.Items[i]
+		if grant.Spec.Subject != subject || !accessGrantActive(grant, now) {
+			continue
+		}
+		denied, err := accessGrantDenied(ctx, c, grant)
+		if err != nil {
+			return nil, err
+		}
+		if denied != "" {
+			continue
+		}
+		policies = append(policies, grant.Spec.Policy)
+	}
+
+	unique := []string{}
+	for _, policy := range policies {
+		if !containsString(unique, policy) {
+			unique = append(unique, policy)
+		}
+	}
+	sort.Strings(unique)
+	return unique, nil
+}
+
+// oidcClaimPolicyName returns the name of the policy merged for an OpenID policy claim value. The prefix
+// keeps it from overwriting policies managed otherwise.
+func oidcClaimPolicyName(claim string) string {
+	return "oidc-" + claim
+}
+
+// policyDocument returns the document of the minio policy, rendered from its Policy resource if there is
+// one, so it does not depend on the Policy being reconciled first, or read from minio otherwise.
+func policyDocument(ctx context.Context, c client.Client, mc *madmin.AdminClient, name string) (string, error) {
+	claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.PolicyList{}, name)
+	if err != nil {
+		return "", err
+	}
+	if policy, ok := claimant.(*pannoiv1beta1.Policy); ok {
+		return RenderPolicy(ctx, c, policy)
+	}
+	doc, err := mc.InfoCannedPolicy(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return string(doc), nil
+}
+
+// applySubjectPolicies replaces the policy mapping of a minio user or group, or an LDAP DN, with the
+// policies collected by subjectPolicies. An empty set removes the mapping.
+//
+// OpenID identities get their policies from a claim, so for an OIDCClaim subject the collected
+// policies are merged into the oidc-<claim value> policy instead.
+func applySubjectPolicies(ctx context.Context, c client.Client, mc *madmin.AdminClient, subject pannoiv1beta1.Subject) error {
+	policies, err := subjectPolicies(ctx, c, subject)
+	if err != nil {
+		return err
+	}
+
+	switch subject.Kind {
+	case "OIDCClaim":
+		if len(policies) == 0 {
+			return removeCannedPolicy(ctx, mc, oidcClaimPolicyName(subject.Name))
+		}
+		var docs []string
+		for _, policy := range policies {
+			doc, err := policyDocument(ctx, c, mc, policy)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		merged, err := mergePolicyDocuments(docs)
+		if err != nil {
+			return err
+		}
+		return mc.AddCannedPolicy(ctx, oidcClaimPolicyName(subject.Name), []byte(merged))
+	case "Group", "LDAPGroup":
+		return mc.SetPolicy(ctx, strings.Join(policies, ","), subject.Name, true)
+	default:
+		return mc.SetPolicy(ctx, strings.Join(policies, ","), subject.Name, false)
+	}
+}
+
+// findBindingsForSubject maps a User or Group to the PolicyBindings of its namespace, which may bind it.
+func (r *PolicyBindingReconciler) findBindingsForSubject(ctx context.Context, obj client.Object) []reconcile.Request {
+	bindings := &pannoiv1beta1.PolicyBindingList{}
+	err := r.List(ctx, bindings, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range bindings.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+			},
+		})
+	}
+	return requests
+}
+
+// findBindingsForPolicy maps a Policy to the PolicyBindings merging it into OIDCClaim policies,
+// so the merged policies follow changes of their sources.
+func (r *PolicyBindingReconciler) findBindingsForPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy := obj.(*pannoiv1beta1.Policy)
+	bindings := &pannoiv1beta1.PolicyBindingList{}
+	err := r.List(ctx, bindings)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range bindings.Items {
+		if !containsSubjectKind(item.Spec.Subjects, "OIDCClaim") {
+			continue
+		}
+		if containsString(item.Spec.Policies, policy.Spec.Name) ||
+			(item.Namespace == policy.Namespace && containsString(item.Spec.PolicyRefs, policy.Name)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+func containsSubjectKind(list []pannoiv1beta1.Subject, kind string) bool {
+	for _, el := range list {
+		if el.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PolicyBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.PolicyBinding{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.User{}, handler.EnqueueRequestsFromMapFunc(r.findBindingsForSubject)).
+		Watches(&pannoiv1beta1.Group{}, handler.EnqueueRequestsFromMapFunc(r.findBindingsForSubject)).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findBindingsForPolicy)).
+		Complete(r)
+}
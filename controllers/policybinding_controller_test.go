@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestSubjectOwned(t *testing.T) {
+	t.Setenv("EXTERNAL_SUBJECT_NAMESPACES", "team-c, team-d")
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+		},
+		&pannoiv1beta1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketAccessSpec{Bucket: "data", Access: "read", User: "app-user"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "developers"},
+		},
+		&pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-c", CreationTimestamp: metav1.NewTime(time.Now().Add(time.Hour))},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "developers"},
+		},
+	)
+
+	tests := []struct {
+		namespace string
+		subject   pannoiv1beta1.Subject
+		owned     bool
+	}{
+		{"team-a", pannoiv1beta1.Subject{Kind: "User", Name: "alice"}, true},
+		{"team-a", pannoiv1beta1.Subject{Kind: "User", Name: "app-user"}, true},
+		{"team-a", pannoiv1beta1.Subject{Kind: "Group", Name: "developers"}, true},
+		{"team-b", pannoiv1beta1.Subject{Kind: "User", Name: "alice"}, false},
+		{"team-b", pannoiv1beta1.Subject{Kind: "Group", Name: "developers"}, false},
+		{"team-c", pannoiv1beta1.Subject{Kind: "Group", Name: "developers"}, false},
+		{"team-a", pannoiv1beta1.Subject{Kind: "User", Name: "bob"}, false},
+		{"team-b", pannoiv1beta1.Subject{Kind: "LDAPUser", Name: "uid=bob,dc=example,dc=com"}, false},
+		{"team-d", pannoiv1beta1.Subject{Kind: "LDAPUser", Name: "uid=bob,dc=example,dc=com"}, true},
+		{"team-d", pannoiv1beta1.Subject{Kind: "OIDCClaim", Name: "team-data"}, true},
+	}
+	for _, test := range tests {
+		owned, err := subjectOwned(context.Background(), c, test.namespace, test.subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owned != test.owned {
+			t.Errorf("%s %s in %s: expected owned=%v", test.subject.Kind, test.subject.Name, test.namespace, test.owned)
+		}
+	}
+}
+
+func TestSubjectPoliciesIgnoresForeignBindings(t *testing.T) {
+	alice := pannoiv1beta1.Subject{Kind: "User", Name: "alice"}
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice", Policies: []string{"team-a.readonly"}},
+		},
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "own", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.PolicyBindingSpec{Policies: []string{"team-a.write"}, Subjects: []pannoiv1beta1.Subject{alice}},
+		},
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "team-b"},
+			Spec:       pannoiv1beta1.PolicyBindingSpec{Policies: []string{"team-b.admin"}, Subjects: []pannoiv1beta1.Subject{alice}},
+		},
+	)
+
+	policies, err := subjectPolicies(context.Background(), c, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(policies, []string{"team-a.readonly", "team-a.write"}) {
+		t.Errorf("unexpected policies %v", policies)
+	}
+}
+
+func TestPolicyDocumentRendersPolicyResource(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "readonly", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicySpec{
+				Name:      "team-a-readonly",
+				Statement: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::team-a/*"]}]}`,
+			},
+		},
+	)
+
+	// The Policy resource is rendered, so minio is not queried for a possibly stale document.
+	doc, err := policyDocument(context.Background(), c, nil, "team-a-readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc, "arn:aws:s3:::team-a/*") {
+		t.Errorf("expected rendered document of the Policy resource, got %s", doc)
+	}
+}
+
+func TestFindBindingsForPolicy(t *testing.T) {
+	policy := &pannoiv1beta1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "readonly", Namespace: "team-a"},
+		Spec:       pannoiv1beta1.PolicySpec{Name: "team-a-readonly"},
+	}
+	r := &PolicyBindingReconciler{Client: newFakeClient(
+		policy,
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "by-name", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicyBindingSpec{
+				Policies: []string{"team-a-readonly"},
+				Subjects: []pannoiv1beta1.Subject{{Kind: "OIDCClaim", Name: "team-a"}},
+			},
+		},
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "by-ref", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicyBindingSpec{
+				PolicyRefs: []string{"readonly"},
+				Subjects:   []pannoiv1beta1.Subject{{Kind: "OIDCClaim", Name: "team-a"}},
+			},
+		},
+		&pannoiv1beta1.PolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "team-a"},
+			Spec: pannoiv1beta1.PolicyBindingSpec{
+				Policies: []string{"team-a-readonly"},
+				Subjects: []pannoiv1beta1.Subject{{Kind: "User", Name: "alice"}},
+			},
+		},
+	)}
+
+	requests := r.findBindingsForPolicy(context.Background(), policy)
+	var names []string
+	for _, req := range requests {
+		names = append(names, req.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"by-name", "by-ref"}) {
+		t.Errorf("expected OIDCClaim bindings of the policy, got %v", names)
+	}
+}
+
+func TestOIDCClaimPolicyName(t *testing.T) {
+	if name := oidcClaimPolicyName("consoleAdmin"); name != "oidc-consoleAdmin" {
+		t.Errorf("expected prefixed policy name, got %s", name)
+	}
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/madmin-go"
+	bucketpolicy "github.com/minio/pkg/bucket/policy"
+	iampolicy "github.com/minio/pkg/iam/policy"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+type PolicyCheckReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// namedPolicyDocument is a policy document with the name reported in matched statements.
+type namedPolicyDocument struct {
+	name     string
+	document *iampolicy.Policy
+}
+
+// parseNamedPolicy parses the policy document with the minio policy engine.
+func parseNamedPolicy(name, raw string) (namedPolicyDocument, error) {
+	document, err := iampolicy.ParseConfig(strings.NewReader(raw))
+	if err != nil {
+		return namedPolicyDocument{}, fmt.Errorf("policy %s: %w", name, err)
+	}
+	return namedPolicyDocument{name: name, document: document}, nil
+}
+
+// policyArgs returns the minio policy arguments of the action on the resource ARN. Condition keys
+// are passed without the aws: and s3: prefixes, as minio evaluates them.
+func policyArgs(action, resource string, requestContext map[string][]string) iampolicy.Args {
+	path := strings.TrimPrefix(resource, "arn:aws:s3:::")
+	bucket, object, _ := strings.Cut(path, "/")
+
+	conditionValues := map[string][]string{}
+	for key, values := range requestContext {
+		conditionValues[strings.TrimPrefix(strings.TrimPrefix(key, "aws:"), "s3:")] = values
+	}
+	return iampolicy.Args{
+		Action:          iampolicy.Action(action),
+		BucketName:      bucket,
+		ObjectName:      object,
+		ConditionValues: conditionValues,
+	}
+}
+
+// evaluatePolicies decides whether the action on the resource is allowed by the policies, using the
+// statement evaluation of minio: an explicit Deny wins over any Allow, without an Allow the request
+// is implicitly denied.
+func evaluatePolicies(policies []namedPolicyDocument, action, resource string, requestContext map[string][]string) (string, []string) {
+	args := policyArgs(action, resource, requestContext)
+
+	var allowed, denied []string
+	for _, policy := range policies {
+		for i, statement := range policy.document.Statements {
+			// Statement.IsAllowed is inverted for Deny statements, which deny when they match.
+			matched := statement.IsAllowed(args) == (statement.Effect == bucketpolicy.Allow)
+			if !matched {
+				continue
+			}
+			id := fmt.Sprintf("%s/Statement[%d]", policy.name, i)
+			if statement.SID != "" {
+				id = policy.name + "/" + string(statement.SID)
+			}
+			if statement.Effect == bucketpolicy.Deny {
+				denied = append(denied, id)
+			} else {
+				allowed = append(allowed, id)
+			}
+		}
+	}
+
+	if len(denied) > 0 {
+		return "ExplicitDeny", denied
+	}
+	if len(allowed) > 0 {
+		return "Allowed", allowed
+	}
+	return "ImplicitDeny", nil
+}
+
+// checkResource returns the ARN of the checked resource, "bucket/key" is accepted as well.
+func checkResource(resource string) string {
+	if resource == "*" || strings.HasPrefix(resource, "arn:") {
+		return resource
+	}
+	return "arn:aws:s3:::" + strings.TrimPrefix(resource, "/")
+}
+
+// checkPolicies collects the policy documents of the check: the ones attached to the user
+// and its groups, the canned policies and the Policy resources rendered locally.
+func (r *PolicyCheckReconciler) checkPolicies(ctx context.Context, mc *madmin.AdminClient, check *pannoiv1beta1.PolicyCheck) ([]namedPolicyDocument, error) {
+	names := append([]string{}, check.Spec.Policies...)
+	if check.Spec.User != "" {
+		userInfo, err := mc.GetUserInfo(ctx, check.Spec.User)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, strings.Split(userInfo.PolicyName, ",")...)
+		for _, group := range userInfo.MemberOf {
+			desc, err := mc.GetGroupDescription(ctx, group)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, strings.Split(desc.Policy, ",")...)
+		}
+	}
+
+	var policies []namedPolicyDocument
+	var seen []string
+	for _, name := range names {
+		if name == "" || containsString(seen, name) {
+			continue
+		}
+		seen = append(seen, name)
+		raw, err := mc.InfoCannedPolicy(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := parseNamedPolicy(name, string(raw))
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	for _, ref := range check.Spec.PolicyRefs {
+		policy := &pannoiv1beta1.Policy{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref, Namespace: check.Namespace}, policy)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := RenderPolicy(ctx, r.Client, policy)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", ref, err)
+		}
+		parsed, err := parseNamedPolicy(policy.Spec.Name, raw)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, parsed)
+	}
+	return policies, nil
+}
+
+func (r *PolicyCheckReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	check := &pannoiv1beta1.PolicyCheck{}
+	err := r.Get(ctx, req.NamespacedName, check)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("PolicyCheck resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get PolicyCheck resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&check.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, check)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	if check.Spec.User != "" {
+		owned, err := subjectOwned(ctx, r.Client, check.Namespace, pannoiv1beta1.Subject{Kind: "User", Name: check.Spec.User})
+		if err != nil {
+			log.Error(err, "Failed to check owner of user "+check.Spec.User)
+			return ctrl.Result{}, err
+		}
+		if !owned {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "User is not owned by namespace",
+				Message: "User " + check.Spec.User + " is not managed by a resource in namespace " + check.Namespace,
+			}
+			check.Status.Error = conditions.Message
+			setCondition(&check.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, check)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Info("User " + check.Spec.User + " is not owned by namespace " + check.Namespace)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	policies, err := r.checkPolicies(ctx, mc, check)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed to get policies",
+			Message: err.Error(),
+		}
+		check.Status.Error = err.Error()
+		setCondition(&check.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, check)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Failed to get policies of PolicyCheck: " + check.Name + ": " + check.Status.Error)
+		return ctrl.Result{RequeueAfter: policyEntitiesRefreshPeriod}, nil
+	}
+
+	requestContext := map[string][]string{}
+	for key, values := range check.Spec.Context {
+		requestContext[key] = values
+	}
+	if check.Spec.User != "" {
+		if _, ok := requestContext["aws:username"]; !ok {
+			requestContext["aws:username"] = []string{check.Spec.User}
+		}
+	}
+
+	decision, matched := evaluatePolicies(policies, check.Spec.Action, checkResource(check.Spec.Resource), requestContext)
+
+	check.Status.Policies = []string{}
+	for _, policy := range policies {
+		check.Status.Policies = append(check.Status.Policies, policy.name)
+	}
+	check.Status.Decision = decision
+	check.Status.Allowed = decision == "Allowed"
+	check.Status.MatchedStatements = matched
+	check.Status.Error = ""
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	setCondition(&check.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, check)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("PolicyCheck was evaluated: " + check.Name + ": " + decision)
+	if check.Spec.User != "" || len(check.Spec.Policies) > 0 {
+		return ctrl.Result{RequeueAfter: policyEntitiesRefreshPeriod}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// findChecksForPolicy maps a Policy to the PolicyChecks referencing it.
+func (r *PolicyCheckReconciler) findChecksForPolicy(ctx context.Context, policy client.Object) []reconcile.Request {
+	checks := &pannoiv1beta1.PolicyCheckList{}
+	err := r.List(ctx, checks, client.InNamespace(policy.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range checks.Items {
+		if containsString(item.Spec.PolicyRefs, policy.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+func (r *PolicyCheckReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.PolicyCheck{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findChecksForPolicy)).
+		Complete(r)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluatePolicies(t *testing.T) {
+	readwrite, err := parseNamedPolicy("readwrite", `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Sid": "Objects", "Effect": "Allow", "Action": ["s3:*Object"], "Resource": ["arn:aws:s3:::data/*"]},
+			{"Effect": "Allow", "Action": ["s3:ListBucket"], "Resource": ["arn:aws:s3:::data"],
+			 "Condition": {"StringLike": {"s3:prefix": ["${aws:username}/*"]}}}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protect, err := parseNamedPolicy("protect", `{
+		"Version": "2012-10-17",
+		"Statement": [{"Sid": "Protected", "Effect": "Deny", "Action": ["s3:DeleteObject"], "Resource": ["arn:aws:s3:::data/protected/*"]}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := []namedPolicyDocument{readwrite, protect}
+
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+		context  map[string][]string
+		decision string
+		matched  []string
+	}{
+		{"allowed", "s3:GetObject", "arn:aws:s3:::data/report.csv", nil, "Allowed", []string{"readwrite/Objects"}},
+		{"explicit deny", "s3:DeleteObject", "arn:aws:s3:::data/protected/report.csv", nil, "ExplicitDeny", []string{"protect/Protected"}},
+		{"other bucket", "s3:GetObject", "arn:aws:s3:::logs/report.csv", nil, "ImplicitDeny", nil},
+		{"condition with variable", "s3:ListBucket", "arn:aws:s3:::data",
+			map[string][]string{"aws:username": {"alice"}, "s3:prefix": {"alice/reports"}}, "Allowed", []string{"readwrite/Statement[1]"}},
+		{"condition not met", "s3:ListBucket", "arn:aws:s3:::data",
+			map[string][]string{"aws:username": {"alice"}, "s3:prefix": {"bob/reports"}}, "ImplicitDeny", nil},
+	}
+	for _, tt := range tests {
+		decision, matched := evaluatePolicies(policies, tt.action, tt.resource, tt.context)
+		if decision != tt.decision || !reflect.DeepEqual(matched, tt.matched) {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.name, tt.decision, tt.matched, decision, matched)
+		}
+	}
+}
+
+func TestParseNamedPolicyRejectsInvalid(t *testing.T) {
+	_, err := parseNamedPolicy("broken", `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"]}]}`)
+	if err == nil {
+		t.Error("expected statement without resource to be rejected")
+	}
+}
@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/madmin-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+const (
+	// defaultSTSAudience is the audience of projected tokens, it must match the client_id of the minio OpenID provider.
+	defaultSTSAudience = "minio"
+	// webIdentityTokenPath is where pods are expected to mount the projected service account token.
+	webIdentityTokenPath = "/var/run/secrets/minio/serviceaccount/token"
+)
+
+type ServiceAccountIdentityReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// identityClaimant returns the oldest live ServiceAccountIdentity of the service account in the namespace,
+// nil if there is none. Only the claimant manages the policy of the service account.
+func identityClaimant(ctx context.Context, c client.Client, namespace, serviceAccountName string) (*pannoiv1beta1.ServiceAccountIdentity, error) {
+	identities := &pannoiv1beta1.ServiceAccountIdentityList{}
+	err := c.List(ctx, identities, client.InNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	var claimant *pannoiv1beta1.ServiceAccountIdentity
+	for i := range identities.Items {
+		identity := &identities.Items[i]
+		if identity.Spec.ServiceAccountName != serviceAccountName || !identity.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if claimant == nil || claimsBefore(identity, claimant) {
+			claimant = identity
+		}
+	}
+	return claimant, nil
+}
+
+// removeIdentityPolicy removes the policy of the service account unless another identity claims it.
+func removeIdentityPolicy(ctx context.Context, c client.Client, mc *madmin.AdminClient, namespace, serviceAccountName string) error {
+	claimant, err := identityClaimant(ctx, c, namespace, serviceAccountName)
+	if err != nil || claimant != nil {
+		return err
+	}
+	return removeCannedPolicy(ctx, mc, serviceAccountSubject(namespace, serviceAccountName))
+}
+
+// serviceAccountSubject returns the "sub" claim of the service account tokens. The minio OpenID provider
+// is expected to use it as policy claim, so the policy named after it is applied to the service account.
+func serviceAccountSubject(namespace, name string) string {
+	return "system:serviceaccount:" + namespace + ":" + name
+}
+
+func (r *ServiceAccountIdentityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	identity := &pannoiv1beta1.ServiceAccountIdentity{}
+	err := r.Get(ctx, req.NamespacedName, identity)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("ServiceAccountIdentity resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ServiceAccountIdentity resource")
+		return ctrl.Result{}, err
+	}
+
+	var minioEndpoint string
+	if strings.Contains(os.Getenv("MINIO_ENDPOINT"), "http") {
+		minioHost, _ := url.Parse(os.Getenv("MINIO_ENDPOINT"))
+		minioEndpoint = minioHost.Host
+	} else {
+		minioEndpoint = os.Getenv("MINIO_ENDPOINT")
+	}
+
+	mc, err := madmin.New(
+		minioEndpoint,
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+		false,
+	)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to connect to minio",
+		}
+		setCondition(&identity.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, identity)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to connect to minio: "+minioEndpoint)
+		return ctrl.Result{}, err
+	}
+
+	policyName := serviceAccountSubject(req.Namespace, identity.Spec.ServiceAccountName)
+
+	if !identity.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(identity, minioFinalizer) {
+			// Identities reconciled before status.serviceAccount was recorded only have status.policy.
+			serviceAccountName := identity.Status.ServiceAccount
+			if serviceAccountName == "" && identity.Status.Policy != "" {
+				serviceAccountName = identity.Spec.ServiceAccountName
+			}
+			if serviceAccountName != "" {
+				err = removeIdentityPolicy(ctx, r.Client, mc, req.Namespace, serviceAccountName)
+				if err != nil {
+					log.Error(err, "Failed to remove policy: "+identity.Status.Policy)
+					return ctrl.Result{}, err
+				}
+			}
+			controllerutil.RemoveFinalizer(identity, minioFinalizer)
+			err = r.Update(ctx, identity)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info("ServiceAccountIdentity was deleted: " + identity.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(identity, minioFinalizer) {
+		controllerutil.AddFinalizer(identity, minioFinalizer)
+		err = r.Update(ctx, identity)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	claimant, err := identityClaimant(ctx, r.Client, req.Namespace, identity.Spec.ServiceAccountName)
+	if err != nil {
+		log.Error(err, "Failed to list identities of service account: "+identity.Spec.ServiceAccountName)
+		return ctrl.Result{}, err
+	}
+	// The cache may not list the identity yet, it is looked up again once it does.
+	if claimant == nil {
+		log.Info("Identity is not listed yet: " + identity.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if claimant.Name != identity.Name {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Duplicate identity",
+			Message: "Service account " + identity.Spec.ServiceAccountName + " is mapped by " + claimant.Name,
+		}
+		setCondition(&identity.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, identity)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Service account " + identity.Spec.ServiceAccountName + " is mapped by " + claimant.Name)
+		return ctrl.Result{}, nil
+	}
+
+	var statements []string
+	for _, ref := range identity.Spec.PolicyRefs {
+		policy := &pannoiv1beta1.Policy{}
+		err = r.Get(ctx, types.NamespacedName{Name: ref, Namespace: req.Namespace}, policy)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to get referenced policy",
+			}
+			setCondition(&identity.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, identity)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to get policy: "+ref)
+			return ctrl.Result{Requeue: true}, nil
+		}
+		document, err := RenderPolicy(ctx, r.Client, policy)
+		if err == nil {
+			err = CheckPolicyGuardrails(ctx, r.Client, policy.Namespace, document)
+		}
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Invalid policy document",
+				Message: err.Error(),
+			}
+			setCondition(&identity.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, identity)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Info("Invalid policy document: " + ref)
+			return ctrl.Result{}, nil
+		}
+		statements = append(statements, document)
+	}
+
+	merged, err := mergePolicyDocuments(statements)
+	if err == nil {
+		err = mc.AddCannedPolicy(ctx, policyName, []byte(merged))
+	}
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed create policy",
+		}
+		setCondition(&identity.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, identity)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create policy: "+policyName)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if identity.Status.ServiceAccount != "" && identity.Status.ServiceAccount != identity.Spec.ServiceAccountName {
+		err = removeIdentityPolicy(ctx, r.Client, mc, req.Namespace, identity.Status.ServiceAccount)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to remove policy",
+			}
+			setCondition(&identity.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, identity)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to remove policy of service account: "+identity.Status.ServiceAccount)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	audience := identity.Spec.Audience
+	if audience == "" {
+		audience = defaultSTSAudience
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      identity.Name + "-minio-identity",
+			Namespace: req.Namespace,
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Data = map[string]string{
+			"AWS_ENDPOINT_URL":            stsEndpoint(),
+			"AWS_ENDPOINT_URL_STS":        stsEndpoint(),
+			"AWS_WEB_IDENTITY_TOKEN_FILE": webIdentityTokenPath,
+			"AWS_ROLE_SESSION_NAME":       identity.Spec.ServiceAccountName,
+			"MINIO_STS_AUDIENCE":          audience,
+		}
+		return ctrl.SetControllerReference(identity, configMap, r.Scheme)
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to create configmap",
+		}
+		setCondition(&identity.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, identity)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create configmap: "+configMap.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	identity.Status.Policy = policyName
+	identity.Status.ServiceAccount = identity.Spec.ServiceAccountName
+	identity.Status.ConfigMap = configMap.Name
+	setCondition(&identity.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, identity)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("ServiceAccountIdentity was reconciled: " + policyName)
+	return ctrl.Result{}, nil
+}
+
+// findIdentitiesForPolicy maps a Policy to the ServiceAccountIdentities referencing it.
+func (r *ServiceAccountIdentityReconciler) findIdentitiesForPolicy(ctx context.Context, policy client.Object) []reconcile.Request {
+	identities := &pannoiv1beta1.ServiceAccountIdentityList{}
+	err := r.List(ctx, identities, client.InNamespace(policy.GetNamespace()))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range identities.Items {
+		if containsString(item.Spec.PolicyRefs, policy.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// findIdentitiesForServiceAccount maps a ServiceAccountIdentity to the other identities of the same
+// service account, so a refused duplicate takes over once the claimant is deleted or remapped.
+func (r *ServiceAccountIdentityReconciler) findIdentitiesForServiceAccount(ctx context.Context, obj client.Object) []reconcile.Request {
+	identity := obj.(*pannoiv1beta1.ServiceAccountIdentity)
+	identities := &pannoiv1beta1.ServiceAccountIdentityList{}
+	err := r.List(ctx, identities, client.InNamespace(identity.Namespace))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range identities.Items {
+		if item.Name == identity.Name {
+			continue
+		}
+		if item.Spec.ServiceAccountName == identity.Spec.ServiceAccountName || item.Spec.ServiceAccountName == identity.Status.ServiceAccount {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.Name,
+					Namespace: item.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+func (r *ServiceAccountIdentityReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.ServiceAccountIdentity{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.Policy{}, handler.EnqueueRequestsFromMapFunc(r.findIdentitiesForPolicy)).
+		Watches(&pannoiv1beta1.ServiceAccountIdentity{}, handler.EnqueueRequestsFromMapFunc(r.findIdentitiesForServiceAccount)).
+		Complete(r)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestMergePolicyDocuments(t *testing.T) {
+	merged, err := mergePolicyDocuments([]string{
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::a/*"}]}`,
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":["s3:DeleteObject"],"Resource":["arn:aws:s3:::b/*"]}]}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := ParsePolicyDocument(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Statement) != 2 || document.Statement[0].Resource[0] != "arn:aws:s3:::a/*" || document.Statement[1].Effect != "Deny" {
+		t.Errorf("expected statements of both documents, got %s", merged)
+	}
+
+	_, err = mergePolicyDocuments([]string{`{"Version":"2012-10-17","Statement":"all"}`})
+	if err == nil {
+		t.Error("expected invalid document to be rejected")
+	}
+}
+
+func TestIdentityClaimant(t *testing.T) {
+	now := metav1.Now()
+	older := metav1.NewTime(now.Add(-time.Hour))
+	c := newFakeClient(
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "team-a", CreationTimestamp: older},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "app"},
+		},
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "team-a", CreationTimestamp: now},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "app"},
+		},
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "leaving", Namespace: "team-a", CreationTimestamp: older, DeletionTimestamp: &now, Finalizers: []string{minioFinalizer}},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+		},
+	)
+
+	claimant, err := identityClaimant(context.Background(), c, "team-a", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimant == nil || claimant.Name != "first" {
+		t.Errorf("expected the oldest identity to claim the service account, got %v", claimant)
+	}
+
+	claimant, err = identityClaimant(context.Background(), c, "team-a", "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimant != nil {
+		t.Errorf("expected no claimant for a service account of deleted identities, got %s", claimant.Name)
+	}
+}
+
+// laggingClient lists no ServiceAccountIdentities, like a cache which has not seen them yet.
+type laggingClient struct {
+	client.Client
+}
+
+func (c laggingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*pannoiv1beta1.ServiceAccountIdentityList); ok {
+		return nil
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestIdentityReconcileNotListedYet(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "127.0.0.1:1")
+	c := newFakeClient(&pannoiv1beta1.ServiceAccountIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "team-a", Finalizers: []string{minioFinalizer}},
+		Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+	})
+	r := &ServiceAccountIdentityReconciler{Client: laggingClient{c}}
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "worker", Namespace: "team-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Requeue {
+		t.Error("expected an identity missing from the cache to be requeued")
+	}
+}
+
+func TestIdentityReconcileDuplicate(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "127.0.0.1:1")
+	c := newFakeClient(
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "team-a", Finalizers: []string{minioFinalizer}},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+		},
+		&pannoiv1beta1.ServiceAccountIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "team-a", Finalizers: []string{minioFinalizer}, CreationTimestamp: metav1.NewTime(time.Now().Add(time.Hour))},
+			Spec:       pannoiv1beta1.ServiceAccountIdentitySpec{ServiceAccountName: "worker"},
+		},
+	)
+	r := &ServiceAccountIdentityReconciler{Client: c}
+	key := types.NamespacedName{Name: "second", Namespace: "team-a"}
+
+	var resourceVersion string
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		identity := &pannoiv1beta1.ServiceAccountIdentity{}
+		err = c.Get(context.Background(), key, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(identity.Status.Conditions) != 1 || identity.Status.Conditions[0].Reason != "Duplicate identity" {
+			t.Errorf("reconcile %d: expected a single Duplicate identity condition, got %+v", i+1, identity.Status.Conditions)
+		}
+		if i == 1 && identity.ResourceVersion != resourceVersion {
+			t.Error("expected an unchanged status not to be written again")
+		}
+		resourceVersion = identity.ResourceVersion
+	}
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+// defaultSTSDuration is the lifetime of temporary credentials if no duration is set.
+const defaultSTSDuration = time.Hour
+
+// minSTSDuration and maxSTSDuration are the lifetimes accepted by minio AssumeRole.
+const (
+	minSTSDuration = 15 * time.Minute
+	maxSTSDuration = 12 * time.Hour
+)
+
+type TemporaryCredentialsReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// refreshTime returns when the credentials are renewed, after 80% of their lifetime.
+func refreshTime(expiresAt time.Time, duration time.Duration) time.Time {
+	return expiresAt.Add(-duration / 5)
+}
+
+// CheckSTSDuration rejects lifetimes of temporary credentials minio would refuse to issue.
+func CheckSTSDuration(duration time.Duration) error {
+	if duration < minSTSDuration || duration > maxSTSDuration {
+		return fmt.Errorf("duration %s must be between %s and %s", duration, minSTSDuration, maxSTSDuration)
+	}
+	return nil
+}
+
+// stsEndpoint returns the minio endpoint URL for STS requests, keeping the https scheme of
+// env:MINIO_ENDPOINT and defaulting to http when no scheme is set.
+func stsEndpoint() string {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if strings.Contains(endpoint, "http") {
+		minioHost, _ := url.Parse(endpoint)
+		return minioHost.Scheme + "://" + minioHost.Host
+	}
+	return "http://" + endpoint
+}
+
+func assumeRole(stsEndpoint string, opts credentials.STSAssumeRoleOptions) (credentials.Value, error) {
+	sts, err := credentials.NewSTSAssumeRole(stsEndpoint, opts)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return sts.Get()
+}
+
+func (r *TemporaryCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	tempCreds := &pannoiv1beta1.TemporaryCredentials{}
+	err := r.Get(ctx, req.NamespacedName, tempCreds)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("TemporaryCredentials resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get TemporaryCredentials resource")
+		return ctrl.Result{}, err
+	}
+
+	duration := defaultSTSDuration
+	if tempCreds.Spec.Duration != nil {
+		duration = tempCreds.Spec.Duration.Duration
+	}
+
+	if tempCreds.Status.ExpiresAt != nil && tempCreds.Status.ObservedGeneration == tempCreds.Generation {
+		refreshAt := refreshTime(tempCreds.Status.ExpiresAt.Time, duration)
+		if time.Now().Before(refreshAt) {
+			return ctrl.Result{RequeueAfter: time.Until(refreshAt)}, nil
+		}
+	}
+
+	err = CheckSTSDuration(duration)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Invalid duration",
+			Message: err.Error(),
+		}
+		setCondition(&tempCreds.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, tempCreds)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Invalid duration of temporary credentials: " + tempCreds.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if tempCreds.Spec.Policy != "" {
+		err = CheckPolicyGuardrails(ctx, r.Client, req.Namespace, tempCreds.Spec.Policy)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Policy violates guardrails",
+				Message: err.Error(),
+			}
+			setCondition(&tempCreds.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, tempCreds)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Info("Session policy of temporary credentials violates guardrails: " + tempCreds.Name)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	user := &pannoiv1beta1.User{}
+	err = r.Get(ctx, types.NamespacedName{Name: tempCreds.Spec.User, Namespace: req.Namespace}, user)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get user",
+		}
+		setCondition(&tempCreds.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, tempCreds)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get user: "+tempCreds.Spec.User)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	userSecret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: user.Spec.Name + "-minio-credentials", Namespace: req.Namespace}, userSecret)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get user credentials",
+		}
+		setCondition(&tempCreds.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, tempCreds)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get credentials of user: "+user.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	value, err := assumeRole(stsEndpoint(), credentials.STSAssumeRoleOptions{
+		AccessKey:       string(userSecret.Data["accessKey"]),
+		SecretKey:       string(userSecret.Data["secretKey"]),
+		Policy:          tempCreds.Spec.Policy,
+		DurationSeconds: int(duration.Seconds()),
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to issue temporary credentials",
+		}
+		setCondition(&tempCreds.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, tempCreds)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to issue temporary credentials for user: "+user.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	issuedAt := time.Now()
+
+	secretName := tempCreds.Spec.SecretName
+	if secretName == "" {
+		secretName = tempCreds.Name + "-minio-sts-credentials"
+	}
+
+	secretMap := make(map[string][]byte)
+	secretMap["accessKey"] = []byte(value.AccessKeyID)
+	secretMap["secretKey"] = []byte(value.SecretAccessKey)
+	secretMap["sessionToken"] = []byte(value.SessionToken)
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: req.Namespace,
+		},
+		Type: corev1.SecretType("generic"),
+		Data: secretMap,
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Data = secretMap
+		return ctrl.SetControllerReference(tempCreds, secret, r.Scheme)
+	})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to create secret",
+		}
+		setCondition(&tempCreds.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, tempCreds)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to create secret with temporary credentials: "+secretName)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	expiresAt := metav1.NewTime(issuedAt.Add(duration))
+	tempCreds.Status.ExpiresAt = &expiresAt
+	tempCreds.Status.ObservedGeneration = tempCreds.Generation
+
+	conditions := metav1.Condition{
+		Status: "Ready",
+		Reason: "Ready",
+	}
+	setCondition(&tempCreds.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, tempCreds)
+	if err != nil {
+		log.Error(err, "Failed to update status conditions")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Temporary credentials were issued: " + tempCreds.Name)
+	return ctrl.Result{RequeueAfter: time.Until(refreshTime(tempCreds.Status.ExpiresAt.Time, duration))}, nil
+}
+
+func (r *TemporaryCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pannoiv1beta1.TemporaryCredentials{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSTSDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		valid    bool
+	}{
+		{time.Minute, false},
+		{15 * time.Minute, true},
+		{time.Hour, true},
+		{12 * time.Hour, true},
+		{24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		err := CheckSTSDuration(tt.duration)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.duration, tt.valid, err)
+		}
+	}
+}
+
+func TestSTSEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"minio.minio.svc:9000":         "http://minio.minio.svc:9000",
+		"http://minio.minio.svc:9000":  "http://minio.minio.svc:9000",
+		"https://minio.minio.svc:9000": "https://minio.minio.svc:9000",
+	}
+	for endpoint, expected := range tests {
+		t.Setenv("MINIO_ENDPOINT", endpoint)
+		if got := stsEndpoint(); got != expected {
+			t.Errorf("%s: expected %s, got %s", endpoint, expected, got)
+		}
+	}
+}
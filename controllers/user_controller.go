@@ -1,18 +1,29 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
-	"math/rand"
+	"crypto/rand"
+	"fmt"
+	"math/big"
 	"net/url"
 	"os"
 	"strings"
 
 	"github.com/minio/madmin-go"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,20 +31,163 @@ import (
 	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
 )
 
+const passwordSecretRefField = ".spec.passwordSecretRef.name"
+
 type UserReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 }
 
-func generatePassword(l int) string {
-	var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+// generatePassword returns a random password of letters and digits read from crypto/rand.
+func generatePassword(l int) (string, error) {
+	var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
 	s := make([]rune, l)
 	for i := range s {
-		s[i] = letterRunes[rand.Intn(len(letterRunes))]
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(letterRunes))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		s[i] = letterRunes[n.Int64()]
+	}
+
+	return string(s), nil
+}
+
+// inlinePolicyName returns the name of the canned policy created from the user inline policy.
+func inlinePolicyName(username string) string {
+	return "user-" + username
+}
+
+// homePolicyName returns the name of the policy restricting users to their home prefix in the bucket.
+func homePolicyName(bucket string) string {
+	return "home-" + bucket
+}
+
+// homeBucket returns the bucket of the home prefix recorded in the user status, empty without home.
+func homeBucket(user *pannoiv1beta1.User) string {
+	if user.Status.Home == "" {
+		return ""
+	}
+	return strings.SplitN(user.Status.Home, "/", 2)[0]
+}
+
+// CheckHomeBucket rejects a home bucket that is not owned by a Bucket resource of the namespace,
+// so users cannot get a home policy for the bucket of another namespace.
+func CheckHomeBucket(ctx context.Context, c client.Client, namespace, bucket string) error {
+	claimant, err := NameClaimant(ctx, c, &pannoiv1beta1.BucketList{}, bucket)
+	if err != nil {
+		return err
+	}
+	if claimant == nil || claimant.GetNamespace() != namespace {
+		return fmt.Errorf("bucket %s is not owned by a Bucket of namespace %s", bucket, namespace)
+	}
+	return nil
+}
+
+// removeHomePolicy removes the home policy of the bucket unless another live User still has its home there.
+func removeHomePolicy(ctx context.Context, c client.Client, mc *madmin.AdminClient, user *pannoiv1beta1.User, bucket string) error {
+	users := &pannoiv1beta1.UserList{}
+	err := c.List(ctx, users)
+	if err != nil {
+		return err
+	}
+	for _, other := range users.Items {
+		if other.Namespace == user.Namespace && other.Name == user.Name {
+			continue
+		}
+		if other.Spec.Home != nil && other.Spec.Home.Bucket == bucket && other.DeletionTimestamp.IsZero() {
+			return nil
+		}
+	}
+	return removeCannedPolicy(ctx, mc, homePolicyName(bucket))
+}
+
+// homePolicy allows users to list and manage objects under the <bucket>/<username>/ prefix only.
+// The policy relies on the ${aws:username} variable, so one policy is shared by all users of the bucket.
+func homePolicy(bucket string) string {
+	return `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Action": ["s3:GetBucketLocation"],
+            "Resource": ["arn:aws:s3:::` + bucket + `"]
+        },
+        {
+            "Effect": "Allow",
+            "Action": ["s3:ListBucket"],
+            "Resource": ["arn:aws:s3:::` + bucket + `"],
+            "Condition": {
+                "StringLike": {
+                    "s3:prefix": ["", "${aws:username}/", "${aws:username}/*"]
+                }
+            }
+        },
+        {
+            "Effect": "Allow",
+            "Action": [
+                "s3:GetObject",
+                "s3:PutObject",
+                "s3:DeleteObject",
+                "s3:AbortMultipartUpload",
+                "s3:ListMultipartUploadParts"
+            ],
+            "Resource": ["arn:aws:s3:::` + bucket + `/${aws:username}/*"]
+        }
+    ]
+}`
+}
+
+// removeCannedPolicy removes the policy from minio, ignoring ones already gone.
+func removeCannedPolicy(ctx context.Context, mc *madmin.AdminClient, name string) error {
+	if name == "" {
+		return nil
+	}
+	err := mc.RemoveCannedPolicy(ctx, name)
+	if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminNoSuchPolicy" {
+		return err
 	}
+	return nil
+}
 
-	return string(s)
+func containsString(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
+
+// userPassword returns the password from spec.passwordSecretRef when it is set,
+// otherwise the one already stored in the credentials secret or a new generated one.
+func (r *UserReconciler) userPassword(ctx context.Context, user *pannoiv1beta1.User, secretName string) (string, error) {
+	if ref := user.Spec.PasswordSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: user.Namespace}, secret)
+		if err != nil {
+			return "", err
+		}
+		password, ok := secret.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+		}
+		return string(password), nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: user.Namespace}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return generatePassword(20)
+		}
+		return "", err
+	}
+	if password, ok := secret.Data["secretKey"]; ok {
+		return string(password), nil
+	}
+	return generatePassword(20)
 }
 
 func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -69,8 +223,8 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			Status: "Failed",
 			Reason: "Failed to connect to minio",
 		}
-		user.Status.Conditions = append(user.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, user)
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
 		if err != nil {
 			log.Error(err, "Failed to update status conditions")
 			return ctrl.Result{}, err
@@ -89,8 +243,108 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 
+	if !user.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(user, minioFinalizer) {
+			if user.Status.InlinePolicy != "" || user.Status.Home != "" {
+				err = applySubjectPolicies(ctx, r.Client, mc, pannoiv1beta1.Subject{Kind: "User", Name: user.Spec.Name})
+				if err != nil {
+					log.Error(err, "Failed to detach inline and home policies from user: "+user.Spec.Name)
+					return ctrl.Result{}, err
+				}
+				err = removeCannedPolicy(ctx, mc, user.Status.InlinePolicy)
+				if err != nil {
+					log.Error(err, "Failed to remove inline policy: "+user.Status.InlinePolicy)
+					return ctrl.Result{}, err
+				}
+				if bucket := homeBucket(user); bucket != "" {
+					err = removeHomePolicy(ctx, r.Client, mc, user, bucket)
+					if err != nil {
+						log.Error(err, "Failed to remove home policy of bucket: "+bucket)
+						return ctrl.Result{}, err
+					}
+				}
+			}
+			controllerutil.RemoveFinalizer(user, minioFinalizer)
+			err = r.Update(ctx, user)
+			if err != nil {
+				log.Error(err, "Failed to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// BucketAccesses manage minio users as well, so they are taken into account as claimants.
+	owner, err := UserNameClaimant(ctx, r.Client, user.Spec.Name)
+	if err != nil {
+		log.Error(err, "Failed to check claims of user name: "+user.Spec.Name)
+		return ctrl.Result{}, err
+	}
+	claimant := ""
+	if other, ok := owner.(*pannoiv1beta1.User); owner != nil && (!ok || other.Namespace != user.Namespace || other.Name != user.Name) {
+		claimant = owner.GetNamespace() + "/" + owner.GetName()
+	}
+	if claimant != "" {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NameConflict",
+			Message: "User name " + user.Spec.Name + " is claimed by " + claimant,
+		}
+		user.Status.NameConflict = claimant
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("User name " + user.Spec.Name + " is claimed by " + claimant)
+		return ctrl.Result{}, nil
+	}
+	user.Status.NameConflict = ""
+
+	err = CheckNamespaceName(ctx, r.Client, user.Namespace, "user", user.Spec.Name)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "NamespacePrefix",
+			Message: err.Error(),
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("User name is not prefixed with namespace prefix: " + user.Spec.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if (user.Spec.InlinePolicy != "" || user.Spec.Home != nil) && !controllerutil.ContainsFinalizer(user, minioFinalizer) {
+		controllerutil.AddFinalizer(user, minioFinalizer)
+		err = r.Update(ctx, user)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
 	username := user.Spec.Name
-	password := generatePassword(20)
+	password, err := r.userPassword(ctx, user, username+"-minio-credentials")
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Failed to get user password",
+			Message: err.Error(),
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get password for user: "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
 
 	err = mc.AddUser(ctx, username, password)
 	if err != nil {
@@ -98,8 +352,8 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			Status: "Failed",
 			Reason: "Failed to create user in minio",
 		}
-		user.Status.Conditions = append(user.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, user)
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
 		if err != nil {
 			log.Error(err, "Failed to update status conditions")
 			return ctrl.Result{}, err
@@ -108,6 +362,44 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	accountStatus := madmin.AccountEnabled
+	if user.Spec.Enabled != nil && !*user.Spec.Enabled {
+		accountStatus = madmin.AccountDisabled
+	}
+
+	err = mc.SetUserStatus(ctx, username, accountStatus)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to set user status",
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to set status "+string(accountStatus)+" for user: "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	userInfo, err := mc.GetUserInfo(ctx, username)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to get user info",
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to get user info: "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	user.Status.AccountStatus = string(userInfo.Status)
+
 	secretMap := make(map[string][]byte)
 	secretMap["accessKey"] = []byte(username)
 	secretMap["secretKey"] = []byte(password)
@@ -125,14 +417,21 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		Data: secretMap,
 	}
 
-	err = r.Create(ctx, secret, &client.CreateOptions{})
+	found := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		err = r.Create(ctx, secret, &client.CreateOptions{})
+	} else if err == nil {
+		found.Data = secretMap
+		err = r.Update(ctx, found)
+	}
 	if err != nil {
 		conditions := metav1.Condition{
 			Status: "Failed",
 			Reason: "Failed to create secret",
 		}
-		user.Status.Conditions = append(user.Status.Conditions, conditions)
-		err = r.Status().Update(ctx, user)
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
 		if err != nil {
 			log.Error(err, "Failed to update status conditions")
 			return ctrl.Result{}, err
@@ -141,31 +440,193 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{Requeue: true}, err
 	}
 
-	if len(user.Spec.Policies) > 0 {
-		for _, el := range user.Spec.Policies {
-			err = mc.SetPolicy(ctx, el, username, false)
+	err = CheckPolicyReferences(ctx, r.Client, user.Namespace, user.Spec.Policies)
+	if err != nil {
+		conditions := metav1.Condition{
+			Status:  "Failed",
+			Reason:  "Policy reference denied",
+			Message: err.Error(),
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Info("Policies of user are denied: " + username)
+		return ctrl.Result{}, nil
+	}
+
+	if user.Spec.InlinePolicy != "" {
+		err = CheckPolicyGuardrails(ctx, r.Client, user.Namespace, user.Spec.InlinePolicy)
+		if err == nil {
+			err = mc.AddCannedPolicy(ctx, inlinePolicyName(username), []byte(user.Spec.InlinePolicy))
+		}
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to create inline policy",
+				Message: err.Error(),
+			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
 			if err != nil {
-				conditions := metav1.Condition{
-					Status: "Failed",
-					Reason: "Failed to attach policy",
-				}
-				user.Status.Conditions = append(user.Status.Conditions, conditions)
-				err = r.Status().Update(ctx, user)
-				if err != nil {
-					log.Error(err, "Failed to update status conditions")
-					return ctrl.Result{}, err
-				}
-				log.Error(err, "Failed to attach policy: "+el+" to user "+username)
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to create inline policy for user: "+username)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	if user.Spec.Home != nil {
+		err = CheckHomeBucket(ctx, r.Client, user.Namespace, user.Spec.Home.Bucket)
+		if err == nil {
+			err = r.provisionHome(ctx, mc, minioEndpoint, username, user.Spec.Home.Bucket)
+		}
+		if err != nil {
+			conditions := metav1.Condition{
+				Status:  "Failed",
+				Reason:  "Failed to provision home prefix",
+				Message: err.Error(),
 			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to provision home prefix for user: "+username)
+			return ctrl.Result{Requeue: true}, nil
 		}
 	}
 
+	// The collected set is always applied, so removing the last policy detaches it.
+	err = applySubjectPolicies(ctx, r.Client, mc, pannoiv1beta1.Subject{Kind: "User", Name: username})
+	if err != nil {
+		conditions := metav1.Condition{
+			Status: "Failed",
+			Reason: "Failed to attach policy",
+		}
+		setCondition(&user.Status.Conditions, conditions)
+		err = updateStatus(ctx, r.Client, user)
+		if err != nil {
+			log.Error(err, "Failed to update status conditions")
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to attach policies to user "+username)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if bucket := homeBucket(user); bucket != "" && (user.Spec.Home == nil || user.Spec.Home.Bucket != bucket) {
+		err = removeHomePolicy(ctx, r.Client, mc, user, bucket)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to remove home policy",
+			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to remove home policy of bucket: "+bucket)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+	user.Status.Home = ""
+	if user.Spec.Home != nil {
+		user.Status.Home = user.Spec.Home.Bucket + "/" + username + "/"
+	}
+
+	if user.Spec.InlinePolicy == "" && user.Status.InlinePolicy != "" {
+		err = removeCannedPolicy(ctx, mc, user.Status.InlinePolicy)
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to remove inline policy",
+			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to remove inline policy: "+user.Status.InlinePolicy)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+	user.Status.InlinePolicy = ""
+	if user.Spec.InlinePolicy != "" {
+		user.Status.InlinePolicy = inlinePolicyName(username)
+	}
+
+	groups, err := managedGroups(ctx, r.Client, user.Namespace, user.Spec.Groups)
+	if err != nil {
+		log.Error(err, "Failed to list groups of user: "+username)
+		return ctrl.Result{}, err
+	}
+	for _, group := range user.Spec.Groups {
+		if !containsString(groups, group) {
+			log.Info("Group " + group + " of user " + username + " is not managed by a Group resource in namespace " + user.Namespace)
+		}
+	}
+
+	for _, group := range groups {
+		err = mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+			Group:   group,
+			Members: []string{username},
+		})
+		if err != nil {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to add user to group",
+			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to add user "+username+" to group "+group)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	for _, group := range user.Status.Groups {
+		if containsString(groups, group) {
+			continue
+		}
+		err = mc.UpdateGroupMembers(ctx, madmin.GroupAddRemove{
+			Group:    group,
+			Members:  []string{username},
+			IsRemove: true,
+		})
+		// The group is gone when its Group resource was deleted.
+		if err != nil && madmin.ToErrorResponse(err).Code != "XMinioAdminNoSuchGroup" {
+			conditions := metav1.Condition{
+				Status: "Failed",
+				Reason: "Failed to remove user from group",
+			}
+			setCondition(&user.Status.Conditions, conditions)
+			err = updateStatus(ctx, r.Client, user)
+			if err != nil {
+				log.Error(err, "Failed to update status conditions")
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to remove user "+username+" from group "+group)
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+	user.Status.Groups = groups
+
 	conditions := metav1.Condition{
 		Status: "Ready",
 		Reason: "Ready",
 	}
-	user.Status.Conditions = append(user.Status.Conditions, conditions)
-	err = r.Status().Update(ctx, user)
+	setCondition(&user.Status.Conditions, conditions)
+	err = updateStatus(ctx, r.Client, user)
 	if err != nil {
 		log.Error(err, "Failed to update status conditions")
 		return ctrl.Result{}, err
@@ -175,8 +636,83 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	return ctrl.Result{}, nil
 }
 
+// provisionHome creates the home policy of the bucket and the <username>/ prefix marker object.
+func (r *UserReconciler) provisionHome(ctx context.Context, mc *madmin.AdminClient, minioEndpoint, username, bucket string) error {
+	err := mc.AddCannedPolicy(ctx, homePolicyName(bucket), []byte(homePolicy(bucket)))
+	if err != nil {
+		return err
+	}
+
+	s3, err := minio.New(minioEndpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY"), ""),
+		Secure: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	prefix := username + "/"
+	_, err = s3.StatObject(ctx, bucket, prefix, minio.StatObjectOptions{})
+	if err == nil {
+		return nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return err
+	}
+
+	_, err = s3.PutObject(ctx, bucket, prefix, bytes.NewReader([]byte{}), 0, minio.PutObjectOptions{})
+	return err
+}
+
+// findUsersForSecret maps a Secret to the Users reading their password from it.
+func (r *UserReconciler) findUsersForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	users := &pannoiv1beta1.UserList{}
+	err := r.List(ctx, users, client.InNamespace(secret.GetNamespace()), client.MatchingFields{passwordSecretRefField: secret.GetName()})
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(users.Items))
+	for i, item := range users.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+			},
+		}
+	}
+	return requests
+}
+
+// findUsersWithSameName maps a User to the other Users claiming the same minio name.
+func (r *UserReconciler) findUsersWithSameName(ctx context.Context, obj client.Object) []reconcile.Request {
+	user := obj.(*pannoiv1beta1.User)
+	return sameNameRequests(ctx, r.Client, &pannoiv1beta1.UserList{}, user, user.Spec.Name)
+}
+
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.User{}, passwordSecretRefField, func(obj client.Object) []string {
+		user := obj.(*pannoiv1beta1.User)
+		if user.Spec.PasswordSecretRef == nil {
+			return nil
+		}
+		return []string{user.Spec.PasswordSecretRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &pannoiv1beta1.User{}, specNameField, func(obj client.Object) []string {
+		return []string{obj.(*pannoiv1beta1.User).Spec.Name}
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&pannoiv1beta1.User{}).
+		For(&pannoiv1beta1.User{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&pannoiv1beta1.User{}, handler.EnqueueRequestsFromMapFunc(r.findUsersWithSameName)).
+		// Only metadata of Secrets is cached, passwords are read from the API server.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findUsersForSecret), builder.OnlyMetadata).
 		Complete(r)
 }
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestCheckHomeBucket(t *testing.T) {
+	c := newFakeClient(
+		&pannoiv1beta1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.BucketSpec{Name: "team-a-data"},
+		},
+	)
+
+	err := CheckHomeBucket(context.Background(), c, "team-a", "team-a-data")
+	if err != nil {
+		t.Errorf("expected bucket of the namespace to be allowed, got %v", err)
+	}
+	err = CheckHomeBucket(context.Background(), c, "team-b", "team-a-data")
+	if err == nil {
+		t.Error("expected bucket of another namespace to be rejected")
+	}
+	err = CheckHomeBucket(context.Background(), c, "team-a", "unmanaged")
+	if err == nil {
+		t.Error("expected bucket without Bucket resource to be rejected")
+	}
+}
+
+func TestHomeBucket(t *testing.T) {
+	user := &pannoiv1beta1.User{Status: pannoiv1beta1.UserStatus{Home: "team-a-data/alice/"}}
+	if bucket := homeBucket(user); bucket != "team-a-data" {
+		t.Errorf("expected team-a-data, got %q", bucket)
+	}
+	if bucket := homeBucket(&pannoiv1beta1.User{}); bucket != "" {
+		t.Errorf("expected no bucket without home, got %q", bucket)
+	}
+}
+
+func TestSubjectPoliciesSkipsHomeOfDeletedUser(t *testing.T) {
+	now := metav1.Now()
+	c := newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a", DeletionTimestamp: &now, Finalizers: []string{minioFinalizer}},
+			Spec: pannoiv1beta1.UserSpec{
+				Name:         "alice",
+				InlinePolicy: `{"Version":"2012-10-17","Statement":[]}`,
+				Home:         &pannoiv1beta1.HomeSpec{Bucket: "team-a-data"},
+			},
+		},
+	)
+
+	policies, err := subjectPolicies(context.Background(), c, pannoiv1beta1.Subject{Kind: "User", Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 0 {
+		t.Errorf("expected no inline or home policy for a deleted user, got %v", policies)
+	}
+}
+
+func TestCheckPolicyGuardrailsInlinePolicy(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-admin")
+	c := newFakeClient()
+
+	err := CheckPolicyGuardrails(context.Background(), c, "team-a",
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["admin:*"],"Resource":["arn:aws:s3:::*"]}]}`)
+	if err == nil {
+		t.Error("expected admin action in inline policy to be rejected")
+	}
+}
+
+func TestFindUsersForSecret(t *testing.T) {
+	r := &UserReconciler{Client: newFakeClient(
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "team-a"},
+			Spec: pannoiv1beta1.UserSpec{Name: "alice", PasswordSecretRef: &pannoiv1beta1.SecretKeyReference{
+				Name: "alice-password", Key: "password",
+			}},
+		},
+		&pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob", Namespace: "team-a"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "bob"},
+		},
+	)}
+
+	// Secrets are watched by metadata only.
+	secret := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "alice-password", Namespace: "team-a"}}
+	requests := r.findUsersForSecret(context.Background(), secret)
+	if len(requests) != 1 || requests[0].NamespacedName != (types.NamespacedName{Name: "alice", Namespace: "team-a"}) {
+		t.Errorf("expected only the user reading the secret, got %v", requests)
+	}
+
+	secret.Namespace = "team-b"
+	if requests := r.findUsersForSecret(context.Background(), secret); len(requests) != 0 {
+		t.Errorf("expected no users for a secret of another namespace, got %v", requests)
+	}
+}
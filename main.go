@@ -6,15 +6,20 @@ import (
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
 	"minio-resource-operator/controllers"
+	"minio-resource-operator/webhooks"
 )
 
 var (
@@ -50,6 +55,14 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "daa4f2b1.pannoi",
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
+		// Secrets and ConfigMaps are watched by metadata only, so they are read from the API server
+		// instead of caching all Secrets and ConfigMaps of the cluster.
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}},
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -77,6 +90,116 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Policy")
 		os.Exit(1)
 	}
+	if err = (&controllers.AccessKeyReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessKey")
+		os.Exit(1)
+	}
+	if err = (&controllers.GroupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Group")
+		os.Exit(1)
+	}
+	if err = (&controllers.PolicyBindingReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PolicyBinding")
+		os.Exit(1)
+	}
+	if err = (&controllers.AccessGrantReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessGrant")
+		os.Exit(1)
+	}
+	if err = (&controllers.TemporaryCredentialsReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TemporaryCredentials")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServiceAccountIdentityReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServiceAccountIdentity")
+		os.Exit(1)
+	}
+	if err = (&controllers.BucketAccessReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BucketAccess")
+		os.Exit(1)
+	}
+	if err = (&controllers.PolicyCheckReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PolicyCheck")
+		os.Exit(1)
+	}
+	if err = (&controllers.MinioResourceQuotaReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MinioResourceQuota")
+		os.Exit(1)
+	}
+
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		mgr.GetWebhookServer().Register("/mutate-v1-pod", &webhook.Admission{Handler: &webhooks.PodCredentialsInjector{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/mutate-v1beta1-bucket", &webhook.Admission{Handler: &webhooks.BucketDefaulter{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-bucket", &webhook.Admission{Handler: &webhooks.BucketValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/mutate-v1beta1-user", &webhook.Admission{Handler: &webhooks.UserDefaulter{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-user", &webhook.Admission{Handler: &webhooks.UserValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/mutate-v1beta1-policy", &webhook.Admission{Handler: &webhooks.PolicyDefaulter{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-policy", &webhook.Admission{Handler: &webhooks.PolicyValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/mutate-v1beta1-group", &webhook.Admission{Handler: &webhooks.GroupDefaulter{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-group", &webhook.Admission{Handler: &webhooks.GroupValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-temporarycredentials", &webhook.Admission{Handler: &webhooks.TemporaryCredentialsValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		mgr.GetWebhookServer().Register("/validate-v1beta1-quota", &webhook.Admission{Handler: &webhooks.ResourceQuotaValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func testScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pannoiv1beta1.AddToScheme(scheme)
+	return scheme
+}
+
+// newFakeClient returns a client serving the objects, with the spec.name indexes of the controllers.
+func newFakeClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(testScheme()).
+		WithObjects(objs...).
+		WithIndex(&pannoiv1beta1.Bucket{}, ".spec.name", func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.Bucket).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.User{}, ".spec.name", func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.User).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.Policy{}, ".spec.name", func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.Policy).Spec.Name}
+		}).
+		WithIndex(&pannoiv1beta1.Group{}, ".spec.name", func(obj client.Object) []string {
+			return []string{obj.(*pannoiv1beta1.Group).Spec.Name}
+		}).
+		Build()
+}
+
+// admissionRequest returns a request of the operation for obj in namespace default, old is set on updates.
+func admissionRequest(t *testing.T, operation admissionv1.Operation, obj, old client.Object) admission.Request {
+	t.Helper()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Namespace: "default",
+	}}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Object = runtime.RawExtension{Raw: raw}
+	if old != nil {
+		raw, err = json.Marshal(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	}
+	return req
+}
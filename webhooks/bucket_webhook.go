@@ -0,0 +1,140 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// bucketNamePattern matches lowercase letters, digits, dots and hyphens, starting and ending with a letter or digit.
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// validateBucketName checks the S3 bucket naming rules.
+func validateBucketName(name string) error {
+	if !bucketNamePattern.MatchString(name) {
+		return fmt.Errorf("bucket name %q must be 3-63 characters of lowercase letters, digits, dots and hyphens, starting and ending with a letter or digit", name)
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, ".-") || strings.Contains(name, "-.") {
+		return fmt.Errorf("bucket name %q must not contain adjacent dots or dots next to hyphens", name)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("bucket name %q must not be formatted as an IP address", name)
+	}
+	if strings.HasPrefix(name, "xn--") || strings.HasSuffix(name, "-s3alias") {
+		return fmt.Errorf("bucket name %q must not start with xn-- or end with -s3alias", name)
+	}
+	return nil
+}
+
+// BucketDefaulter defaults spec.name of Buckets to metadata.name, prefixed with the namespace prefix
+// in the "prefix" namespace naming mode.
+type BucketDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (d *BucketDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	bucket := &pannoiv1beta1.Bucket{}
+	err := d.Decoder.Decode(req, bucket)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if bucket.Spec.Name == "" {
+		bucket.Spec.Name = bucket.Name
+	}
+	if req.Operation == admissionv1.Create {
+		bucket.Spec.Name, err = prefixedName(ctx, d.Client, req.Namespace, bucket.Spec.Name)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	marshaled, err := json.Marshal(bucket)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// BucketValidator validates Bucket naming, object locking and quota, keeps spec.name unique and immutable
+// and enforces the MinioResourceQuotas of the namespace.
+type BucketValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (v *BucketValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	bucket := &pannoiv1beta1.Bucket{}
+	err := v.Decoder.Decode(req, bucket)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !bucket.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &pannoiv1beta1.Bucket{}
+		err = v.Decoder.DecodeRaw(req.OldObject, old)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.Spec.Name != bucket.Spec.Name {
+			return admission.Denied("spec.name is immutable")
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, bucket, old)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	if req.Operation == admissionv1.Create {
+		err = checkNameClaim(ctx, v.Client, &pannoiv1beta1.BucketList{}, bucket.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckNamespaceName(ctx, v.Client, req.Namespace, "bucket", bucket.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, bucket, nil)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	err = validateBucketName(bucket.Spec.Name)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	if bucket.Spec.Quota != nil && bucket.Spec.Quota.Sign() <= 0 {
+		return admission.Denied("spec.quota must be a positive size")
+	}
+
+	if bucket.Spec.ObjectLocking.Enabled {
+		mode := strings.ToLower(bucket.Spec.ObjectLocking.Mode)
+		if mode != "" && mode != "governance" && mode != "compliance" {
+			return admission.Denied(fmt.Sprintf("spec.objectLocking.mode %q must be Governance or Compliance", bucket.Spec.ObjectLocking.Mode))
+		}
+		if bucket.Spec.ObjectLocking.Retention <= 0 {
+			return admission.Denied("spec.objectLocking.retention must be a positive number of days")
+		}
+	}
+
+	return admission.Allowed("")
+}
@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestBucketValidatorQuotaRequired(t *testing.T) {
+	quota := resource.MustParse("10Gi")
+	stored := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "default-data", Quota: &quota},
+	}
+	v := &BucketValidator{
+		Client: newFakeClient(
+			stored,
+			&pannoiv1beta1.MinioResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Spec:       pannoiv1beta1.MinioResourceQuotaSpec{BucketQuota: &quota},
+			},
+		),
+		Decoder: admission.NewDecoder(testScheme()),
+	}
+
+	removed := stored.DeepCopy()
+	removed.Spec.Quota = nil
+	resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, removed, stored))
+	if resp.Allowed {
+		t.Error("expected removing the quota of a bucket to be rejected")
+	}
+
+	unlimited := &pannoiv1beta1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "default"},
+		Spec:       pannoiv1beta1.BucketSpec{Name: "default-logs"},
+	}
+	resp = v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, unlimited, nil))
+	if resp.Allowed {
+		t.Error("expected bucket without quota to be rejected")
+	}
+
+	resp = v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, stored, stored))
+	if !resp.Allowed {
+		t.Errorf("expected unchanged bucket to be allowed, got %s", resp.Result.Message)
+	}
+}
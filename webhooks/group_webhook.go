@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// GroupDefaulter defaults spec.name of Groups to metadata.name, prefixed with the namespace prefix
+// in the "prefix" namespace naming mode.
+type GroupDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (d *GroupDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	group := &pannoiv1beta1.Group{}
+	err := d.Decoder.Decode(req, group)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if group.Spec.Name == "" {
+		group.Spec.Name = group.Name
+	}
+	if req.Operation == admissionv1.Create {
+		group.Spec.Name, err = prefixedName(ctx, d.Client, req.Namespace, group.Spec.Name)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	marshaled, err := json.Marshal(group)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// GroupValidator keeps spec.name of Groups unique and immutable, limits members and policies to the
+// ones of the namespace and enforces the MinioResourceQuotas of the namespace.
+type GroupValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (v *GroupValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	group := &pannoiv1beta1.Group{}
+	err := v.Decoder.Decode(req, group)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !group.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &pannoiv1beta1.Group{}
+		err = v.Decoder.DecodeRaw(req.OldObject, old)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.Spec.Name != group.Spec.Name {
+			return admission.Denied("spec.name is immutable")
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, group, old)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	if req.Operation == admissionv1.Create {
+		err = checkNameClaim(ctx, v.Client, &pannoiv1beta1.GroupList{}, group.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckNamespaceName(ctx, v.Client, req.Namespace, "group", group.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, group, nil)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	err = controllers.CheckGroupMembers(ctx, v.Client, req.Namespace, group.Spec.Members)
+	if err != nil {
+		return admission.Denied("spec.members: " + err.Error())
+	}
+
+	err = controllers.CheckPolicyReferences(ctx, v.Client, req.Namespace, group.Spec.Policies)
+	if err != nil {
+		return admission.Denied("spec.policies: " + err.Error())
+	}
+
+	return admission.Allowed("")
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestGroupValidator(t *testing.T) {
+	zero := int32(0)
+	v := &GroupValidator{
+		Client: newFakeClient(
+			&pannoiv1beta1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "default"},
+				Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+			},
+			&pannoiv1beta1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "bob", Namespace: "other"},
+				Spec:       pannoiv1beta1.UserSpec{Name: "bob"},
+			},
+			&pannoiv1beta1.Group{
+				ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "other"},
+				Spec:       pannoiv1beta1.GroupSpec{Name: "admins"},
+			},
+			&pannoiv1beta1.MinioResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "restricted"},
+				Spec:       pannoiv1beta1.MinioResourceQuotaSpec{Groups: &zero},
+			},
+		),
+		Decoder: admission.NewDecoder(testScheme()),
+	}
+
+	tests := []struct {
+		name    string
+		group   *pannoiv1beta1.Group
+		allowed bool
+	}{
+		{"own member", &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "default"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "devs", Members: []string{"alice"}},
+		}, true},
+		{"claimed name", &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "default"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "admins"},
+		}, false},
+		{"member of another namespace", &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "default"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "devs", Members: []string{"bob"}},
+		}, false},
+		{"unmanaged member", &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "default"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "devs", Members: []string{"root"}},
+		}, false},
+		{"quota", &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "restricted"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: "devs"},
+		}, false},
+	}
+	for _, tt := range tests {
+		req := admissionRequest(t, admissionv1.Create, tt.group, nil)
+		req.Namespace = tt.group.Namespace
+		resp := v.Handle(context.Background(), req)
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v (%s)", tt.name, tt.allowed, resp.Allowed, resp.Result.Message)
+		}
+	}
+}
+
+func TestGroupValidatorNamePrefix(t *testing.T) {
+	t.Setenv("NAMESPACE_NAMING", "require")
+	v := &GroupValidator{
+		Client:  newFakeClient(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}),
+		Decoder: admission.NewDecoder(testScheme()),
+	}
+
+	for name, allowed := range map[string]bool{"default.devs": true, "devs": false} {
+		group := &pannoiv1beta1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "default"},
+			Spec:       pannoiv1beta1.GroupSpec{Name: name},
+		}
+		resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, group, nil))
+		if resp.Allowed != allowed {
+			t.Errorf("%s: expected allowed %v, got %v (%s)", name, allowed, resp.Allowed, resp.Result.Message)
+		}
+	}
+}
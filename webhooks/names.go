@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"minio-resource-operator/controllers"
+)
+
+// checkNameClaim rejects a minio name which is already claimed by another resource of the same kind,
+// possibly in another namespace.
+func checkNameClaim(ctx context.Context, c client.Client, list client.ObjectList, name string) error {
+	claimant, err := controllers.NameClaimant(ctx, c, list, name)
+	if err != nil {
+		return err
+	}
+	if claimant != nil {
+		return fmt.Errorf("spec.name %q is already claimed by %s/%s", name, claimant.GetNamespace(), claimant.GetName())
+	}
+	return nil
+}
+
+// prefixedName prefixes the minio name with the namespace prefix in the "prefix" namespace naming mode.
+func prefixedName(ctx context.Context, c client.Client, namespace, name string) (string, error) {
+	if controllers.NamespaceNaming() != "prefix" {
+		return name, nil
+	}
+	prefix, err := controllers.NamespacePrefix(ctx, c, namespace)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(name, prefix) {
+		return name, nil
+	}
+	return prefix + name, nil
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+const (
+	// UserAnnotation selects the User whose credentials are injected into the pod.
+	UserAnnotation = "minio-resource-operator.pannoi/user"
+	// AccessKeyAnnotation selects the AccessKey whose credentials are injected into the pod.
+	AccessKeyAnnotation = "minio-resource-operator.pannoi/access-key"
+	// BucketAccessAnnotation selects the BucketAccess whose credentials are injected into the pod.
+	BucketAccessAnnotation = "minio-resource-operator.pannoi/bucket-access"
+
+	defaultRegion = "us-east-1"
+)
+
+// PodCredentialsInjector sets AWS environment variables of the minio credentials secret
+// on containers of annotated pods.
+type PodCredentialsInjector struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (i *PodCredentialsInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	err := i.Decoder.Decode(req, pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	secretName, err := i.credentialsSecret(ctx, req.Namespace, pod.Annotations)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if secretName == "" {
+		return admission.Allowed("no minio credentials requested")
+	}
+
+	env := credentialsEnv(secretName)
+	for idx := range pod.Spec.InitContainers {
+		injectEnv(&pod.Spec.InitContainers[idx], env)
+	}
+	for idx := range pod.Spec.Containers {
+		injectEnv(&pod.Spec.Containers[idx], env)
+	}
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// credentialsSecret returns the name of the secret selected by the pod annotations, empty if none is set.
+func (i *PodCredentialsInjector) credentialsSecret(ctx context.Context, namespace string, annotations map[string]string) (string, error) {
+	if name, ok := annotations[UserAnnotation]; ok {
+		user := &pannoiv1beta1.User{}
+		err := i.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, user)
+		if err != nil {
+			return "", err
+		}
+		return user.Spec.Name + "-minio-credentials", nil
+	}
+
+	if name, ok := annotations[AccessKeyAnnotation]; ok {
+		accessKey := &pannoiv1beta1.AccessKey{}
+		err := i.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, accessKey)
+		if err != nil {
+			return "", err
+		}
+		if accessKey.Spec.SecretName != "" {
+			return accessKey.Spec.SecretName, nil
+		}
+		return accessKey.Name + "-minio-access-key", nil
+	}
+
+	if name, ok := annotations[BucketAccessAnnotation]; ok {
+		access := &pannoiv1beta1.BucketAccess{}
+		err := i.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, access)
+		if err != nil {
+			return "", err
+		}
+		return controllers.BucketAccessSecret(access), nil
+	}
+
+	return "", nil
+}
+
+func credentialsEnv(secretName string) []corev1.EnvVar {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if !strings.Contains(endpoint, "http") {
+		endpoint = "http://" + endpoint
+	}
+
+	region := os.Getenv("MINIO_REGION")
+	if region == "" {
+		region = defaultRegion
+	}
+
+	return []corev1.EnvVar{
+		{
+			Name: "AWS_ACCESS_KEY_ID",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  "accessKey",
+				},
+			},
+		},
+		{
+			Name: "AWS_SECRET_ACCESS_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  "secretKey",
+				},
+			},
+		},
+		{Name: "AWS_ENDPOINT_URL", Value: endpoint},
+		{Name: "AWS_REGION", Value: region},
+		{Name: "AWS_DEFAULT_REGION", Value: region},
+	}
+}
+
+// injectEnv appends the variables not yet defined by the container.
+func injectEnv(container *corev1.Container, env []corev1.EnvVar) {
+	for _, v := range env {
+		defined := false
+		for _, existing := range container.Env {
+			if existing.Name == v.Name {
+				defined = true
+				break
+			}
+		}
+		if !defined {
+			container.Env = append(container.Env, v)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// PolicyDefaulter defaults spec.name of Policies to metadata.name, prefixed with the namespace prefix
+// in the "prefix" namespace naming mode.
+type PolicyDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (d *PolicyDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	policy := &pannoiv1beta1.Policy{}
+	err := d.Decoder.Decode(req, policy)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if policy.Spec.Name == "" {
+		policy.Spec.Name = policy.Name
+	}
+	if req.Operation == admissionv1.Create {
+		policy.Spec.Name, err = prefixedName(ctx, d.Client, req.Namespace, policy.Spec.Name)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	marshaled, err := json.Marshal(policy)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// PolicyValidator validates policy documents, keeps spec.name unique and immutable and rejects
+// Policies violating the operator guardrails or the MinioResourceQuotas of the namespace.
+type PolicyValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (v *PolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	policy := &pannoiv1beta1.Policy{}
+	err := v.Decoder.Decode(req, policy)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !policy.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &pannoiv1beta1.Policy{}
+		err = v.Decoder.DecodeRaw(req.OldObject, old)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.Spec.Name != policy.Spec.Name {
+			return admission.Denied("spec.name is immutable")
+		}
+	}
+
+	if req.Operation == admissionv1.Create {
+		err = checkNameClaim(ctx, v.Client, &pannoiv1beta1.PolicyList{}, policy.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckNamespaceName(ctx, v.Client, req.Namespace, "policy", policy.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, policy, nil)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	// Statements rendered from templates are validated once they are rendered.
+	if policy.Spec.Statement != "" && !policy.Spec.Template {
+		document, err := controllers.ParsePolicyDocument(policy.Spec.Statement)
+		if err == nil {
+			err = controllers.ValidatePolicyDocument(document)
+		}
+		if err != nil {
+			return admission.Denied("spec.statement: " + err.Error())
+		}
+	}
+	if policy.Spec.Document != nil {
+		err = controllers.ValidatePolicyDocument(policy.Spec.Document)
+		if err != nil {
+			return admission.Denied("spec.document: " + err.Error())
+		}
+	}
+
+	// Policies referencing resources which do not exist yet are admitted with a warning and checked by the
+	// controller once they can be rendered, any other render error is final.
+	document, err := controllers.RenderPolicy(ctx, v.Client, policy)
+	if errors.IsNotFound(err) {
+		return admission.Allowed("").WithWarnings("policy is not rendered yet, guardrails are checked by the controller: " + err.Error())
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	err = controllers.CheckPolicyGuardrails(ctx, v.Client, req.Namespace, document)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
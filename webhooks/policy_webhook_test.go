@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestPolicyValidatorRenderErrors(t *testing.T) {
+	v := &PolicyValidator{Client: newFakeClient(), Decoder: admission.NewDecoder(testScheme())}
+
+	tests := []struct {
+		name    string
+		spec    pannoiv1beta1.PolicySpec
+		allowed bool
+		warned  bool
+	}{
+		{
+			name:    "missing included policy",
+			spec:    pannoiv1beta1.PolicySpec{Name: "read", Include: []string{"missing"}},
+			allowed: true,
+			warned:  true,
+		},
+		{
+			name: "missing configmap",
+			spec: pannoiv1beta1.PolicySpec{Name: "read", StatementFrom: &pannoiv1beta1.StatementSource{
+				ConfigMapKeyRef: &pannoiv1beta1.ConfigMapKeyReference{Name: "missing", Key: "policy.json"},
+			}},
+			allowed: true,
+			warned:  true,
+		},
+		{
+			name: "statement and statementFrom",
+			spec: pannoiv1beta1.PolicySpec{Name: "read", Statement: `{"Version":"2012-10-17","Statement":[]}`, StatementFrom: &pannoiv1beta1.StatementSource{
+				ConfigMapKeyRef: &pannoiv1beta1.ConfigMapKeyReference{Name: "missing", Key: "policy.json"},
+			}},
+			allowed: false,
+		},
+		{
+			name:    "invalid template",
+			spec:    pannoiv1beta1.PolicySpec{Name: "read", Statement: `{{ .Name`, Template: true},
+			allowed: false,
+		},
+		{
+			name:    "literal braces",
+			spec:    pannoiv1beta1.PolicySpec{Name: "read", Statement: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::data/{{ .Name }}/*"]}]}`},
+			allowed: true,
+		},
+	}
+	for _, tt := range tests {
+		policy := &pannoiv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: "default"},
+			Spec:       tt.spec,
+		}
+		resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, policy, nil))
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v (%s)", tt.name, tt.allowed, resp.Allowed, resp.Result.Message)
+		}
+		if (len(resp.Warnings) > 0) != tt.warned {
+			t.Errorf("%s: expected warning %v, got %v", tt.name, tt.warned, resp.Warnings)
+		}
+	}
+}
+
+func TestPolicyValidatorGuardrails(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-wildcard")
+	v := &PolicyValidator{Client: newFakeClient(), Decoder: admission.NewDecoder(testScheme())}
+
+	policy := &pannoiv1beta1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "default"},
+		Spec: pannoiv1beta1.PolicySpec{
+			Name:      "all",
+			Statement: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:*Object*"],"Resource":["arn:aws:s3:::**"]}]}`,
+		},
+	}
+	resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, policy, nil))
+	if resp.Allowed {
+		t.Error("expected wildcard object actions on all buckets to be rejected")
+	}
+}
@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// ResourceQuotaValidator enforces the MinioResourceQuotas of the namespace on the resources which
+// have no validator of their own but create minio users or policies: BucketAccesses and
+// ServiceAccountIdentities.
+type ResourceQuotaValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// newQuotaObject returns an empty object of the admitted kind.
+func newQuotaObject(kind string) (client.Object, error) {
+	switch kind {
+	case "BucketAccess":
+		return &pannoiv1beta1.BucketAccess{}, nil
+	case "ServiceAccountIdentity":
+		return &pannoiv1beta1.ServiceAccountIdentity{}, nil
+	}
+	return nil, fmt.Errorf("unexpected kind %s", kind)
+}
+
+func (v *ResourceQuotaValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj, err := newQuotaObject(req.Kind.Kind)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	err = v.Decoder.Decode(req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return admission.Allowed("")
+	}
+
+	var old client.Object
+	if req.Operation == admissionv1.Update {
+		old, _ = newQuotaObject(req.Kind.Kind)
+		err = v.Decoder.DecodeRaw(req.OldObject, old)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	err = controllers.CheckResourceQuotas(ctx, v.Client, obj, old)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
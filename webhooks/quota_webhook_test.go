@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestResourceQuotaValidator(t *testing.T) {
+	one := int32(1)
+	v := &ResourceQuotaValidator{
+		Client: newFakeClient(
+			&pannoiv1beta1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "default"},
+				Spec:       pannoiv1beta1.UserSpec{Name: "alice"},
+			},
+			&pannoiv1beta1.MinioResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Spec:       pannoiv1beta1.MinioResourceQuotaSpec{Users: &one},
+			},
+		),
+		Decoder: admission.NewDecoder(testScheme()),
+	}
+
+	tests := []struct {
+		kind    string
+		obj     client.Object
+		allowed bool
+	}{
+		{"BucketAccess", &pannoiv1beta1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}, false},
+		{"ServiceAccountIdentity", &pannoiv1beta1.ServiceAccountIdentity{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}}, true},
+	}
+	for _, tt := range tests {
+		req := admissionRequest(t, admissionv1.Create, tt.obj, nil)
+		req.Kind = metav1.GroupVersionKind{Group: "minio-resource-operator.pannoi", Version: "v1beta1", Kind: tt.kind}
+		resp := v.Handle(context.Background(), req)
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v (%s)", tt.kind, tt.allowed, resp.Allowed, resp.Result.Message)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// TemporaryCredentialsValidator rejects TemporaryCredentials with a duration minio would refuse or a session
+// policy violating the guardrails.
+type TemporaryCredentialsValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (v *TemporaryCredentialsValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	tempCreds := &pannoiv1beta1.TemporaryCredentials{}
+	err := v.Decoder.Decode(req, tempCreds)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !tempCreds.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if tempCreds.Spec.Duration != nil {
+		err = controllers.CheckSTSDuration(tempCreds.Spec.Duration.Duration)
+		if err != nil {
+			return admission.Denied("spec.duration: " + err.Error())
+		}
+	}
+
+	if tempCreds.Spec.Policy != "" {
+		err = controllers.CheckPolicyGuardrails(ctx, v.Client, req.Namespace, tempCreds.Spec.Policy)
+		if err != nil {
+			return admission.Denied("spec.policy: " + err.Error())
+		}
+	}
+
+	return admission.Allowed("")
+}
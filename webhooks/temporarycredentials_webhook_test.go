@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestTemporaryCredentialsValidatorDuration(t *testing.T) {
+	v := &TemporaryCredentialsValidator{Client: newFakeClient(), Decoder: admission.NewDecoder(testScheme())}
+
+	tests := []struct {
+		duration *metav1.Duration
+		allowed  bool
+	}{
+		{nil, true},
+		{&metav1.Duration{Duration: time.Hour}, true},
+		{&metav1.Duration{Duration: time.Minute}, false},
+		{&metav1.Duration{Duration: 24 * time.Hour}, false},
+	}
+	for _, tt := range tests {
+		tempCreds := &pannoiv1beta1.TemporaryCredentials{
+			ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "default"},
+			Spec:       pannoiv1beta1.TemporaryCredentialsSpec{User: "alice", Duration: tt.duration},
+		}
+		resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tempCreds, nil))
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%v: expected allowed %v, got %v (%s)", tt.duration, tt.allowed, resp.Allowed, resp.Result.Message)
+		}
+	}
+}
+
+func TestTemporaryCredentialsValidatorPolicy(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-admin")
+	v := &TemporaryCredentialsValidator{Client: newFakeClient(), Decoder: admission.NewDecoder(testScheme())}
+
+	tempCreds := &pannoiv1beta1.TemporaryCredentials{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "default"},
+		Spec: pannoiv1beta1.TemporaryCredentialsSpec{
+			User:   "alice",
+			Policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["admin:*"],"Resource":["arn:aws:s3:::*"]}]}`,
+		},
+	}
+	resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tempCreds, nil))
+	if resp.Allowed {
+		t.Error("expected session policy with admin actions to be rejected")
+	}
+}
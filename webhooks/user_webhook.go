@@ -0,0 +1,144 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+	"minio-resource-operator/controllers"
+)
+
+// validateUsername checks the minio access key rules for user names.
+func validateUsername(name string) error {
+	if len(name) < 3 || len(name) > 128 {
+		return fmt.Errorf("user name %q must be 3-128 characters", name)
+	}
+	if strings.ContainsAny(name, "=,") || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("user name %q must not contain whitespace, '=' or ','", name)
+	}
+	return nil
+}
+
+// UserDefaulter defaults spec.name of Users to metadata.name, prefixed with the namespace prefix
+// in the "prefix" namespace naming mode.
+type UserDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (d *UserDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	user := &pannoiv1beta1.User{}
+	err := d.Decoder.Decode(req, user)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if user.Spec.Name == "" {
+		user.Spec.Name = user.Name
+	}
+	if req.Operation == admissionv1.Create {
+		user.Spec.Name, err = prefixedName(ctx, d.Client, req.Namespace, user.Spec.Name)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	marshaled, err := json.Marshal(user)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// UserValidator validates User names, keeps spec.name unique and immutable and enforces
+// the MinioResourceQuotas of the namespace.
+type UserValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+func (v *UserValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	user := &pannoiv1beta1.User{}
+	err := v.Decoder.Decode(req, user)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Objects being deleted only get their finalizers removed.
+	if !user.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &pannoiv1beta1.User{}
+		err = v.Decoder.DecodeRaw(req.OldObject, old)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.Spec.Name != user.Spec.Name {
+			return admission.Denied("spec.name is immutable")
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, user, old)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	if req.Operation == admissionv1.Create {
+		claimant, err := controllers.UserNameClaimant(ctx, v.Client, user.Spec.Name)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if claimant != nil {
+			return admission.Denied(fmt.Sprintf("spec.name %q is already claimed by %s/%s", user.Spec.Name, claimant.GetNamespace(), claimant.GetName()))
+		}
+		err = controllers.CheckNamespaceName(ctx, v.Client, req.Namespace, "user", user.Spec.Name)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = controllers.CheckResourceQuotas(ctx, v.Client, user, nil)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	err = validateUsername(user.Spec.Name)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	if user.Spec.InlinePolicy != "" {
+		document, err := controllers.ParsePolicyDocument(user.Spec.InlinePolicy)
+		if err == nil {
+			err = controllers.ValidatePolicyDocument(document)
+		}
+		if err == nil {
+			err = controllers.CheckPolicyGuardrails(ctx, v.Client, req.Namespace, user.Spec.InlinePolicy)
+		}
+		if err != nil {
+			return admission.Denied("spec.inlinePolicy: " + err.Error())
+		}
+	}
+
+	err = controllers.CheckPolicyReferences(ctx, v.Client, req.Namespace, user.Spec.Policies)
+	if err != nil {
+		return admission.Denied("spec.policies: " + err.Error())
+	}
+
+	if user.Spec.Home != nil {
+		err = controllers.CheckHomeBucket(ctx, v.Client, req.Namespace, user.Spec.Home.Bucket)
+		if err != nil {
+			return admission.Denied("spec.home: " + err.Error())
+		}
+	}
+
+	return admission.Allowed("")
+}
@@ -0,0 +1,36 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pannoiv1beta1 "minio-resource-operator/api/v1beta1"
+)
+
+func TestUserValidatorPolicies(t *testing.T) {
+	t.Setenv("POLICY_GUARDRAILS", "forbid-admin")
+	v := &UserValidator{Client: newFakeClient(), Decoder: admission.NewDecoder(testScheme())}
+
+	tests := []struct {
+		policies []string
+		allowed  bool
+	}{
+		{nil, true},
+		{[]string{"consoleAdmin"}, false},
+		{[]string{"unmanaged"}, false},
+	}
+	for _, tt := range tests {
+		user := &pannoiv1beta1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "default"},
+			Spec:       pannoiv1beta1.UserSpec{Name: "alice", Policies: tt.policies},
+		}
+		resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, user, nil))
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%v: expected allowed %v, got %v (%s)", tt.policies, tt.allowed, resp.Allowed, resp.Result.Message)
+		}
+	}
+}